@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// middlewareRequireStaff guards the admin area: only moderators and admins get through.
+// Routes that need more than a moderator are wrapped with requireRole.
+// The role is read from the database on every request, so taking it away works right away.
+// Tokens with reduced scopes can't be used for anything that needs a role.
+func (cfg *apiConfig) middlewareRequireStaff(next http.Handler) http.HandlerFunc {
+	return cfg.middlewareRequireScopes(func(w http.ResponseWriter, r *http.Request) {
+		userID := requestUserID(r)
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			log.Printf("Error getting user from database: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		role := auth.Role(user.Role)
+		if !role.AtLeast(auth.RoleModerator) {
+			logSecurityEvent(r, "admin_denied", userID, r.Method+" "+r.URL.Path)
+			respondError(w, "Operation unauthorized", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey, role)))
+	}, auth.AllScopes...)
+}
+
+// requireRole only lets requests through from users with at least the role.
+// Without middlewareRequireStaff in front of it, it refuses every request.
+func requireRole(next http.HandlerFunc, role auth.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staffRole, ok := r.Context().Value(roleKey).(auth.Role)
+		if !ok {
+			log.Printf("requireRole used without middlewareRequireStaff on %s %s", r.Method, r.URL.Path)
+			respondError(w, "Operation unauthorized", http.StatusForbidden)
+			return
+		}
+		if !staffRole.AtLeast(role) {
+			logSecurityEvent(r, "admin_denied", requestUserID(r), r.Method+" "+r.URL.Path)
+			respondError(w, "Operation unauthorized", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (cfg *apiConfig) handleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	// Makes a user a moderator or an admin, or takes it away
+	type setRoleRequest struct {
+		Role string `json:"role"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing user id", http.StatusBadRequest)
+		return
+	}
+	reqBody := setRoleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+	role, err := auth.ParseRole(reqBody.Role)
+	if err != nil {
+		respondError(w, "Roles are user, moderator or admin", http.StatusBadRequest)
+		return
+	}
+	// Otherwise the last admin could leave no one to manage the roles
+	adminID := requestUserID(r)
+	if userID == adminID {
+		respondError(w, "Admins can't change their own role", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{ID: userID, Role: string(role)})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error setting user role: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logSecurityEvent(r, "role_changed", user.ID, "role="+user.Role+" by="+adminID.String())
+
+	type setRoleResponse struct {
+		UserID uuid.UUID `json:"user_id"`
+		Role   string    `json:"role"`
+	}
+	dat, err := json.Marshal(setRoleResponse{UserID: user.ID, Role: user.Role})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// bootstrapAdmin runs the bootstrap-admin command, which creates the first admin:
+//
+//	chirpy bootstrap-admin -email admin@example.com < password.txt
+//
+// An existing user with the address is made an admin, keeping their password.
+// Otherwise the user is created with the password read from the first line of stdin.
+// It refuses to run once there is an admin, further ones are made with PUT /admin/users/{id}/role.
+func bootstrapAdmin(ctx context.Context, db *database.Queries, args []string) error {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	emailFlag := flags.String("email", "", "e-mail address of the admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	email, ok := parseEmail(*emailFlag)
+	if !ok {
+		return errors.New("a valid -email is required")
+	}
+
+	admins, err := db.CountAdmins(ctx)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return errors.New("there already is an admin")
+	}
+
+	user, err := db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return fmt.Errorf("no password on stdin: %v", err)
+		}
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		user, err = db.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashedPassword})
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	_, err = db.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: string(auth.RoleAdmin)})
+	if err != nil {
+		return err
+	}
+	log.Printf("SECURITY event=admin_bootstrapped user=%s", user.ID)
+	return nil
+}
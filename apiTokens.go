@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// apiTokenResponse describes a personal API token. The token itself is only included once, when it's created.
+type apiTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newAPITokenResponse(t database.ApiToken) apiTokenResponse {
+	resp := apiTokenResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.ExpiresAt.Valid {
+		resp.ExpiresAt = &t.ExpiresAt.Time
+	}
+	if t.LastUsedAt.Valid {
+		resp.LastUsedAt = &t.LastUsedAt.Time
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	// API tokens let bots and scripts act for the user without storing a password.
+	// They can't allow more than the token used to create them.
+	type createAPITokenRequest struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+
+	id := requestIdentity(r)
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := createAPITokenRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	if reqBody.Name == "" || len(reqBody.Name) > 100 {
+		respondError(w, "Tokens need a name of at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	scopes, ok := reduceScopes(w, id, reqBody.Scopes)
+	if !ok {
+		return
+	}
+	// Without an expiry, the token lasts until it's revoked
+	expiresAt := sql.NullTime{}
+	if reqBody.ExpiresInSeconds < 0 {
+		respondError(w, "Invalid expiry", http.StatusBadRequest)
+		return
+	} else if reqBody.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(reqBody.ExpiresInSeconds) * time.Second),
+			Valid: true,
+		}
+	}
+	// A login session can create tokens that outlive it. An API token or a downscoped JWT
+	// can't, or a short-lived token could be traded for one that never expires.
+	if (id.APIToken || !id.HasScopes(auth.AllScopes...)) && !id.ExpiresAt.IsZero() {
+		if !expiresAt.Valid || expiresAt.Time.After(id.ExpiresAt) {
+			expiresAt = sql.NullTime{Time: id.ExpiresAt, Valid: true}
+		}
+	}
+
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		log.Printf("Error generating api token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	apiToken, err := cfg.db.CreateAPIToken(r.Context(), database.CreateAPITokenParams{
+		UserID:    id.UserID,
+		Name:      reqBody.Name,
+		TokenHash: auth.HashAPIToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error recording api token in database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := newAPITokenResponse(apiToken)
+	resp.Token = token
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerGetAPITokens(w http.ResponseWriter, r *http.Request) {
+	// Lists the user's tokens that haven't been revoked, newest first
+	tokens, err := cfg.db.ListAPITokens(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Error getting api tokens from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := []apiTokenResponse{}
+	for _, t := range tokens {
+		resp = append(resp, newAPITokenResponse(t))
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing token id", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeAPIToken(r.Context(), database.RevokeAPITokenParams{
+		ID:     tokenID,
+		UserID: requestUserID(r),
+	})
+	if err != nil {
+		log.Printf("Error revoking api token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		respondError(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
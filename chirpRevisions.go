@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	// Handles both PUT and PATCH, the only editable field is the body.
+	// The previous body is kept in the chirp's revision history.
+	type updateChirpRequest struct {
+		Body string `json:"body"`
+	}
+
+	inUID := requestUserID(r)
+
+	reqId, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		log.Printf("Error parsing chirp id: %s", err)
+		respondError(w, "Error parsing chirp id", http.StatusBadRequest)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := updateChirpRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	// Only the author can edit, the limits are theirs
+	ent, err := cfg.userEntitlements(r.Context(), inUID)
+	if err != nil {
+		log.Printf("Error getting entitlements: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	moderated, err := prepareChirpBody(cfg.moderator, reqBody.Body, ent.MaxChirpLength)
+	if err != nil {
+		respondInvalidChirp(w, err)
+		return
+	}
+
+	// The chirp row stays locked until the revision and the new body are both written,
+	// so concurrent edits can't lose a revision
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetChirpByIdForUpdate(r.Context(), reqId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Chirp not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting chirp from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if chirp.UserID != inUID {
+		respondError(w, "Operation unauthorized", http.StatusForbidden)
+		return
+	}
+	if chirp.RechirpOf.Valid {
+		respondError(w, "Rechirps can't be edited", http.StatusBadRequest)
+		return
+	}
+	if time.Since(chirp.CreatedAt) > ent.EditWindow {
+		respondError(w, "Chirp can no longer be edited", http.StatusForbidden)
+		return
+	}
+
+	// An edit that changes nothing doesn't deserve a revision
+	if moderated.Text != chirp.Body {
+		_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+			ChirpID:   chirp.ID,
+			Body:      chirp.Body,
+			CreatedAt: chirp.UpdatedAt,
+		})
+		if err != nil {
+			log.Printf("Error recording chirp revision: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		chirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+			ID:   chirp.ID,
+			Body: moderated.Text,
+		})
+		if err != nil {
+			log.Printf("Error updating chirp: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		if err := indexChirpText(r.Context(), qtx, chirp); err != nil {
+			log.Printf("Error indexing chirp: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		if err := flagChirp(r.Context(), qtx, chirp.ID, moderated); err != nil {
+			log.Printf("Error flagging chirp for review: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing chirp edit: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := cfg.presentChirp(r.Context(), uuid.NullUUID{UUID: inUID, Valid: true}, chirp)
+	if err != nil {
+		log.Printf("Error preparing chirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	// Returns the previous bodies of a chirp, oldest first.
+	// The current body isn't included, it's part of the chirp itself.
+	reqId, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		log.Printf("Error parsing chirp id: %s", err)
+		respondError(w, "Error parsing chirp id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := cfg.db.GetChirpById(r.Context(), reqId); err != nil {
+		respondError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(r.Context(), reqId)
+	if err != nil {
+		log.Printf("Error getting chirp revisions from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if revisions == nil {
+		revisions = []database.ChirpRevision{}
+	}
+
+	dat, err := json.Marshal(revisions)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -1,40 +1,60 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"sort"
-	"strings"
+	"net/url"
+	"time"
 
 	"github.com/Denisowiec/Chirpy/internal/auth"
 	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/moderation"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
 	"github.com/google/uuid"
 )
 
-func replaceProfane(s string) string {
-	grawlix := "****"
-	profanities := []string{
-		"kerfuffle",
-		"sharbert",
-		"fornax",
-	}
-	split := strings.Fields(s)
-	for _, prof := range profanities {
-		for i, word := range split {
-			if strings.ToLower(word) == prof {
-				split[i] = grawlix
-			}
-		}
+var (
+	errChirpEmpty    = errors.New("chirp is empty")
+	errChirpTooLong  = errors.New("chirp is too long")
+	errChirpRejected = errors.New("chirp contains forbidden words")
+)
+
+// prepareChirpBody validates the body of a new or edited chirp
+// and runs it through moderation. The moderated body is in the result's Text.
+func prepareChirpBody(m moderation.Moderator, body string, maxLength int) (moderation.Result, error) {
+	if len(body) == 0 {
+		return moderation.Result{}, errChirpEmpty
+	} else if len(body) > maxLength {
+		return moderation.Result{}, errChirpTooLong
+	}
+	res := m.Moderate(body)
+	if res.Action == moderation.Reject {
+		return moderation.Result{}, errChirpRejected
 	}
+	return res, nil
+}
 
-	return strings.Join(split, " ")
+func respondInvalidChirp(w http.ResponseWriter, err error) {
+	if errors.Is(err, errChirpTooLong) {
+		respondError(w, "Chirp is too long", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errChirpRejected) {
+		respondError(w, "Chirp contains forbidden words", http.StatusBadRequest)
+		return
+	}
+	respondError(w, "Chirp malformed", http.StatusBadRequest)
 }
 
 func (cfg *apiConfig) handlerPostChirp(w http.ResponseWriter, r *http.Request) {
 	type chirpMinimal struct {
-		Body   string    `json:"body"`
-		UserID uuid.UUID `json:"user_id"`
+		Body      string     `json:"body"`
+		UserID    uuid.UUID  `json:"user_id"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	chirpInput := chirpMinimal{}
@@ -48,93 +68,371 @@ func (cfg *apiConfig) handlerPostChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// User authentification
-	token, err := auth.GetBearerToken(r.Header)
+	inUID := requestUserID(r)
+	if !cfg.checkEmailVerified(w, r, inUID) {
+		return
+	}
+
+	// How long chirps can be, and how many, depends on the user's membership
+	ent, err := cfg.userEntitlements(r.Context(), inUID)
 	if err != nil {
-		log.Printf("Error extracting jwt from header: %s", err)
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
+		log.Printf("Error getting entitlements: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
-	inUID, err := auth.ValidateJWT(token, cfg.jwtSecretCode)
+
+	moderated, err := prepareChirpBody(cfg.moderator, chirpInput.Body, ent.MaxChirpLength)
 	if err != nil {
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
+		respondInvalidChirp(w, err)
 		return
 	}
 
-	// Testing if the chirp is too long
-	if len(chirpInput.Body) == 0 {
-		respondError(w, "Chirp malformed", http.StatusBadRequest)
+	// A new chirp starts its own thread, a reply joins the thread of its parent
+	ccparams := database.CreateChirpParams{
+		ID:     uuid.New(),
+		Body:   moderated.Text,
+		UserID: inUID,
+	}
+	ccparams.ThreadID = ccparams.ID
+	if chirpInput.InReplyTo != nil {
+		parent, err := cfg.db.GetChirpById(r.Context(), *chirpInput.InReplyTo)
+		if err != nil {
+			respondError(w, "Chirp replied to not found", http.StatusNotFound)
+			return
+		}
+		// Replying to a rechirp means replying to the chirp that was rechirped
+		if parent.RechirpOf.Valid {
+			parent, err = cfg.db.GetChirpById(r.Context(), parent.RechirpOf.UUID)
+			if err != nil {
+				respondError(w, "Chirp replied to not found", http.StatusNotFound)
+				return
+			}
+		}
+		ccparams.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		ccparams.ThreadID = parent.ThreadID
+		ccparams.AncestorIds = append(parent.AncestorIds, parent.ID)
+	}
+	if chirpInput.QuoteOf != nil {
+		quoted, err := cfg.db.GetChirpById(r.Context(), *chirpInput.QuoteOf)
+		if err != nil {
+			respondError(w, "Quoted chirp not found", http.StatusNotFound)
+			return
+		}
+		// Quoting a rechirp quotes the chirp that was rechirped
+		ccparams.QuoteOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		if quoted.RechirpOf.Valid {
+			ccparams.QuoteOf = quoted.RechirpOf
+		}
+	}
+
+	// The chirp, its hashtags, mentions and moderation flag are stored together
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
 		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	} else if len(chirpInput.Body) > 140 {
-		respondError(w, "Chirp is too long", http.StatusBadRequest)
+	if !checkChirpRate(w, r, qtx, inUID, ent) {
 		return
-	} else {
+	}
+	chirp, err := qtx.CreateChirp(r.Context(), ccparams)
+	if err != nil {
+		log.Printf("Error putting chirp into database: %v", err)
+		respondError(w, "Couldn't process the chirp into database", http.StatusBadRequest)
+		return
+	}
+	if err := indexChirpText(r.Context(), qtx, chirp); err != nil {
+		log.Printf("Error indexing chirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := flagChirp(r.Context(), qtx, chirp.ID, moderated); err != nil {
+		log.Printf("Error flagging chirp for review: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := enqueueWebhookEvent(r.Context(), qtx, inUID, webhookChirpCreated, newWebhookChirp(chirp)); err != nil {
+		log.Printf("Error queueing webhooks: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing chirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := cfg.presentChirp(r.Context(), uuid.NullUUID{UUID: inUID, Valid: true}, chirp)
+	if err != nil {
+		log.Printf("Error preparing chirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated) // Code 201
 
-		chirpInput.Body = replaceProfane(chirpInput.Body)
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling JSON %s", err)
+		return
+	}
+	w.Write(dat)
+}
 
-		ccparams := database.CreateChirpParams{
-			Body:   chirpInput.Body,
-			UserID: inUID,
-		}
+// chirpResponse is a chirp as it's presented to clients.
+// LikedByMe depends on who's asking, so it's filled in by presentChirps,
+// as is Original, the rechirped or quoted chirp embedded one level deep.
+// If a quoted chirp has been deleted, OriginalDeleted is set instead.
+type chirpResponse struct {
+	ID              uuid.UUID       `json:"id"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	Body            string          `json:"body"`
+	UserID          uuid.UUID       `json:"user_id"`
+	InReplyTo       uuid.NullUUID   `json:"in_reply_to"`
+	ThreadID        uuid.UUID       `json:"thread_id"`
+	Mentions        []mentionEntity `json:"mentions"`
+	RechirpOf       uuid.NullUUID   `json:"rechirp_of"`
+	QuoteOf         uuid.NullUUID   `json:"quote_of"`
+	Original        *chirpResponse  `json:"original,omitempty"`
+	OriginalDeleted bool            `json:"original_deleted,omitempty"`
+	LikeCount       int32           `json:"like_count"`
+	LikedByMe       bool            `json:"liked_by_me"`
+}
 
-		chirp, err := cfg.db.CreateChirp(r.Context(), ccparams)
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	return chirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		InReplyTo: chirp.InReplyTo,
+		ThreadID:  chirp.ThreadID,
+		Mentions:  []mentionEntity{},
+		RechirpOf: chirp.RechirpOf,
+		QuoteOf:   chirp.QuoteOf,
+		LikeCount: chirp.LikeCount,
+	}
+}
+
+// originalOf returns the id of the chirp a rechirp or a quote refers to
+func originalOf(chirp database.Chirp) uuid.NullUUID {
+	if chirp.RechirpOf.Valid {
+		return chirp.RechirpOf
+	}
+	return chirp.QuoteOf
+}
+
+// presentChirps prepares chirps to be sent to the viewer.
+// An anonymous viewer (not Valid) hasn't liked anything.
+func (cfg *apiConfig) presentChirps(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]chirpResponse, error) {
+	// The originals of rechirps and quotes are fetched in one query and presented with the rest
+	originalIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if id := originalOf(chirp); id.Valid {
+			originalIDs = append(originalIDs, id.UUID)
+		}
+	}
+	originals := []database.Chirp{}
+	if len(originalIDs) > 0 {
+		var err error
+		originals, err = cfg.db.GetChirpsByIDs(ctx, originalIDs)
 		if err != nil {
-			log.Printf("Error putting chirp into database: %v", err)
-			respondError(w, "Couldn't process the chirp into database", http.StatusBadRequest)
-			return
+			return nil, err
 		}
-		w.WriteHeader(http.StatusCreated) // Code 201
+	}
 
-		dat, err := json.Marshal(chirp)
-		if err != nil {
-			log.Printf("Error marshalling JSON %s", err)
-			return
+	all := make([]database.Chirp, 0, len(chirps)+len(originals))
+	all = append(all, chirps...)
+	all = append(all, originals...)
+	presented, err := cfg.presentChirpsFlat(ctx, viewer, all)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := presented[:len(chirps)]
+	byID := make(map[uuid.UUID]chirpResponse, len(originals))
+	for _, original := range presented[len(chirps):] {
+		byID[original.ID] = original
+	}
+	for i, chirp := range chirps {
+		id := originalOf(chirp)
+		if !id.Valid {
+			continue
+		}
+		if original, ok := byID[id.UUID]; ok {
+			resp[i].Original = &original
+		} else {
+			resp[i].OriginalDeleted = true
+		}
+	}
+	return resp, nil
+}
+
+// presentChirpsFlat does the work of presentChirps, without embedding the originals
+func (cfg *apiConfig) presentChirpsFlat(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]chirpResponse, error) {
+	resp := make([]chirpResponse, len(chirps))
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		resp[i] = newChirpResponse(chirp)
+		ids[i] = chirp.ID
+	}
+	if len(chirps) == 0 {
+		return resp, nil
+	}
+
+	mentions, err := cfg.getChirpMentions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp {
+		if m, ok := mentions[resp[i].ID]; ok {
+			resp[i].Mentions = m
 		}
-		w.Write(dat)
 	}
 
+	if !viewer.Valid {
+		return resp, nil
+	}
+
+	liked, err := cfg.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	likedSet := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+	for i := range resp {
+		resp[i].LikedByMe = likedSet[resp[i].ID]
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) presentChirp(ctx context.Context, viewer uuid.NullUUID, chirp database.Chirp) (chirpResponse, error) {
+	resp, err := cfg.presentChirps(ctx, viewer, []database.Chirp{chirp})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return resp[0], nil
+}
+
+// getViewer identifies the user behind an optional access token.
+// Public endpoints don't require one, so a missing or invalid token,
+// or one without the read scope, means an anonymous viewer.
+func (cfg *apiConfig) getViewer(r *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	id, err := cfg.authenticate(r.Context(), token)
+	if err != nil || !id.HasScopes(auth.ScopeRead) {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id.UserID, Valid: true}
+}
+
+// indexChirpText rebuilds everything derived from the body of a chirp: its hashtags and mentions
+func indexChirpText(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := indexChirpTags(ctx, q, chirp); err != nil {
+		return err
+	}
+	return indexChirpMentions(ctx, q, chirp)
+}
+
+// chirpsPage is the envelope for paginated lists of chirps.
+// NextCursor is empty when there are no more chirps to fetch.
+type chirpsPage struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func chirpCursor(chirp database.Chirp) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
+
+// parseAuthorFilter reads the optional author_id parameter of chirp listings.
+// If it's given, only the chirps of that user are returned.
+func parseAuthorFilter(query url.Values) (uuid.NullUUID, error) {
+	s := query.Get("author_id")
+	if s == "" {
+		return uuid.NullUUID{}, nil
+	}
+	uid, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: uid, Valid: true}, nil
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
-	authorID := r.URL.Query().Get("author_id")
-	sortDir := r.URL.Query().Get("sort")
+	// Chirps are paginated with opaque cursors. With sort=asc the next page is
+	// fetched by passing next_cursor as "after", with sort=desc as "before".
+	query := r.URL.Query()
+	sortDir := query.Get("sort")
 	if sortDir != "desc" {
 		sortDir = "asc"
 	}
-	var chirps []database.Chirp
-	if authorID != "" {
-		// If this parameter is given, we only return the chirps of the given user
-		uid, err := uuid.Parse(authorID)
-		if err != nil {
-			respondError(w, "Error parsing author_id", http.StatusBadRequest)
-			return
-		}
 
-		chirps, err = cfg.db.GetChirpsForUser(r.Context(), uid)
+	page, err := pagination.ParseQuery(query)
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
 
-		if err != nil {
-			respondError(w, "Error fetching chirps for user", http.StatusNotFound)
-			return
-		}
-	} else {
-		// if author_id isn't given, we return all chirps
-		var err error
-		chirps, err = cfg.db.GetChirps(r.Context())
-		if err != nil {
-			log.Printf("Error getting chirps from the database: %s", err)
-			respondError(w, "Something went wrong", http.StatusInternalServerError)
-			return
-		}
+	authorID, err := parseAuthorFilter(query)
+	if err != nil {
+		respondError(w, "Error parsing author_id", http.StatusBadRequest)
+		return
 	}
+
+	// We fetch one chirp more than requested to find out if there's a next page
+	var chirps []database.Chirp
 	if sortDir == "desc" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) })
+		chirps, err = cfg.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			AfterCreatedAt:  page.After.CreatedAt,
+			AfterID:         page.After.ID,
+			BeforeCreatedAt: page.Before.CreatedAt,
+			BeforeID:        page.Before.ID,
+			MaxRows:         page.Limit + 1,
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			AfterCreatedAt:  page.After.CreatedAt,
+			AfterID:         page.After.ID,
+			BeforeCreatedAt: page.Before.CreatedAt,
+			BeforeID:        page.Before.ID,
+			MaxRows:         page.Limit + 1,
+		})
+	}
+	if err != nil {
+		log.Printf("Error getting chirps from the database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
 	}
 
-	dat, err := json.Marshal(chirps)
+	resp := chirpsPage{}
+	chirps, resp.NextCursor = pagination.Trim(chirps, page.Limit, chirpCursor)
+	resp.Chirps, err = cfg.presentChirps(r.Context(), cfg.getViewer(r), chirps)
+	if err != nil {
+		log.Printf("Error preparing chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(resp)
 	if err != nil {
 		log.Printf("Error marshalling data")
 		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK) // Code 200
@@ -156,7 +454,14 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dat, err := json.Marshal(chirp)
+	resp, err := cfg.presentChirp(r.Context(), cfg.getViewer(r), chirp)
+	if err != nil {
+		log.Printf("Error preparing chirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(resp)
 	if err != nil {
 		log.Printf("Error marshalling data: %s", err)
 		respondError(w, "Internal server error", http.StatusInternalServerError)
@@ -169,18 +474,7 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	// User authentification
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error extracting jwt from header: %s", err)
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
-		return
-	}
-	inUID, err := auth.ValidateJWT(token, cfg.jwtSecretCode)
-	if err != nil {
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
-		return
-	}
+	inUID := requestUserID(r)
 
 	reqId, err := uuid.Parse(r.PathValue("chirpid"))
 	if err != nil {
@@ -199,16 +493,38 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The chirp.deleted webhooks are queued only if the chirp is really deleted
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Rechirps of this chirp are removed by the database together with it.
+	// Quotes stay, and will present the original as deleted.
 	delChirpParams := database.DeleteChirpParams{
 		ID:     reqId,
 		UserID: inUID,
 	}
-	_, err = cfg.db.DeleteChirp(r.Context(), delChirpParams)
+	_, err = qtx.DeleteChirp(r.Context(), delChirpParams)
 	if err != nil {
 		log.Printf("Error deleting chirp: %s", err)
 		respondError(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
+	if err := enqueueWebhookEvent(r.Context(), qtx, inUID, webhookChirpDeleted, newWebhookChirp(chirp)); err != nil {
+		log.Printf("Error queueing webhooks: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing chirp deletion: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNoContent)
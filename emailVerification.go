@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// E-mail verification tokens have to be used within a day
+const emailVerificationLifetime = 24 * time.Hour
+
+// parseEmail checks the syntax of an e-mail address from a request.
+// Only a bare address is accepted, without a display name or angle brackets.
+func parseEmail(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", false
+	}
+	// A domain without a dot can only be local, which is no use for sending e-mails
+	at := strings.LastIndex(email, "@")
+	if !strings.Contains(email[at+1:], ".") {
+		return "", false
+	}
+	return email, true
+}
+
+// sendEmailVerification e-mails a user a token proving they own their current address.
+// Tokens sent earlier stop working.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeEmailToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.UseEmailVerifications(ctx, userID); err != nil {
+		return err
+	}
+	_, err = qtx.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: auth.HashEmailToken(token),
+		ExpiresAt: time.Now().UTC().Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	cfg.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Verify your Chirpy e-mail address",
+		Body: fmt.Sprintf("To confirm this is your e-mail address, use this verification token within a day:\n\n%s\n\n"+
+			"If you don't have a Chirpy account, you can ignore this e-mail.\n", token),
+	})
+	return nil
+}
+
+// checkEmailVerified enforces REQUIRE_VERIFIED_EMAIL on posting.
+// If the user may not post, the response has been written.
+func (cfg *apiConfig) checkEmailVerified(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if !cfg.requireVerifiedEmail {
+		return true
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		respondError(w, "Verify your e-mail address first", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	// Marks an address verified. The token is the proof, no access token is needed,
+	// so the link in the e-mail works from any device.
+	type verifyRequest struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := verifyRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	verification, err := qtx.GetEmailVerificationForUpdate(r.Context(), auth.HashEmailToken(reqBody.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error getting verification token from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if verification.UsedAt.Valid || time.Now().UTC().After(verification.ExpiresAt) {
+		respondError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+
+	// Nothing is verified if the user has moved to another address since
+	verified, err := qtx.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if err != nil {
+		log.Printf("Error verifying e-mail: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if verified == 0 {
+		respondError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+	if err := qtx.UseEmailVerifications(r.Context(), verification.UserID); err != nil {
+		log.Printf("Error using verification token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing e-mail verification: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	// Sends a new verification e-mail, for when the last one expired or got lost
+	user, err := cfg.db.GetUserByID(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		respondError(w, "E-mail address already verified", http.StatusConflict)
+		return
+	}
+
+	if err := cfg.sendEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Error sending verification e-mail: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// userEntitlements are the limits and features of a user's membership tier
+func (cfg *apiConfig) userEntitlements(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	return cfg.entitlements.For(entitlements.TierOf(user.IsChirpyRed)), nil
+}
+
+// checkChirpRate refuses a new chirp when the user posted as many as their tier allows in the last hour.
+// q has to be the transaction the chirp is created in: the user's row is locked until it ends,
+// so concurrent posts are counted one after the other instead of all passing the check.
+// If the chirp is refused, the response has been written.
+func checkChirpRate(w http.ResponseWriter, r *http.Request, q *database.Queries, userID uuid.UUID, ent entitlements.Entitlements) bool {
+	if ent.ChirpsPerHour == 0 {
+		return true
+	}
+	if err := q.LockUser(r.Context(), userID); err != nil {
+		log.Printf("Error locking user: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return false
+	}
+	posted, err := q.CountRecentChirps(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error counting recent chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return false
+	}
+	if posted >= int64(ent.ChirpsPerHour) {
+		respondError(w, "Too many chirps, try again later", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handlerGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	// What the user's membership allows, so clients can show the right limits
+	type entitlementsResponse struct {
+		Tier              entitlements.Tier `json:"tier"`
+		MaxChirpLength    int               `json:"max_chirp_length"`
+		EditWindowSeconds int               `json:"edit_window_seconds"`
+		ChirpsPerHour     int               `json:"chirps_per_hour"`
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	tier := entitlements.TierOf(user.IsChirpyRed)
+	ent := cfg.entitlements.For(tier)
+
+	dat, err := json.Marshal(entitlementsResponse{
+		Tier:              tier,
+		MaxChirpLength:    ent.MaxChirpLength,
+		EditWindowSeconds: int(ent.EditWindow.Seconds()),
+		ChirpsPerHour:     ent.ChirpsPerHour,
+	})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
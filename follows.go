@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+// followEntry is a single account in a list of followers or followed accounts
+type followEntry struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followsPage struct {
+	Users      []followEntry `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func followCursor(f followEntry) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: f.FollowedAt, ID: f.UserID}
+}
+
+func (cfg *apiConfig) handleFollowUser(w http.ResponseWriter, r *http.Request) {
+	// Following is idempotent, following the same account twice changes nothing
+	inUID := requestUserID(r)
+
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing user id", http.StatusBadRequest)
+		return
+	}
+	if followeeID == inUID {
+		respondError(w, "Users can't follow themselves", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), followeeID); err != nil {
+		respondError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: inUID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Error recording follow in database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	inUID := requestUserID(r)
+
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing user id", http.StatusBadRequest)
+		return
+	}
+
+	_, err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: inUID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Error removing follow from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.respondFollowList(w, r, func(uid uuid.UUID, page pagination.Params) ([]followEntry, error) {
+		rows, err := cfg.db.ListFollowers(r.Context(), database.ListFollowersParams{
+			UserID:          uid,
+			BeforeCreatedAt: page.Before.CreatedAt,
+			BeforeID:        page.Before.ID,
+			MaxRows:         page.Limit + 1,
+		})
+		entries := make([]followEntry, len(rows))
+		for i, row := range rows {
+			entries[i] = followEntry{UserID: row.UserID, FollowedAt: row.FollowedAt}
+		}
+		return entries, err
+	})
+}
+
+func (cfg *apiConfig) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.respondFollowList(w, r, func(uid uuid.UUID, page pagination.Params) ([]followEntry, error) {
+		rows, err := cfg.db.ListFollowing(r.Context(), database.ListFollowingParams{
+			UserID:          uid,
+			BeforeCreatedAt: page.Before.CreatedAt,
+			BeforeID:        page.Before.ID,
+			MaxRows:         page.Limit + 1,
+		})
+		entries := make([]followEntry, len(rows))
+		for i, row := range rows {
+			entries[i] = followEntry{UserID: row.UserID, FollowedAt: row.FollowedAt}
+		}
+		return entries, err
+	})
+}
+
+// respondFollowList does the parts the followers and following lists have in common.
+// Both are sorted newest first, the next page is fetched by passing next_cursor as "before".
+func (cfg *apiConfig) respondFollowList(w http.ResponseWriter, r *http.Request, list func(uuid.UUID, pagination.Params) ([]followEntry, error)) {
+	uid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing user id", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), uid); err != nil {
+		respondError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	entries, err := list(uid, page)
+	if err != nil {
+		log.Printf("Error getting follows from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := followsPage{}
+	resp.Users, resp.NextCursor = pagination.Trim(entries, page.Limit, followCursor)
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	// The home timeline holds the chirps of everyone the user follows, newest first.
+	// The next page is fetched by passing next_cursor as "before".
+	inUID := requestUserID(r)
+
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := cfg.db.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID:          inUID,
+		AfterCreatedAt:  page.After.CreatedAt,
+		AfterID:         page.After.ID,
+		BeforeCreatedAt: page.Before.CreatedAt,
+		BeforeID:        page.Before.ID,
+		MaxRows:         page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting timeline from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := chirpsPage{}
+	chirps, resp.NextCursor = pagination.Trim(chirps, page.Limit, chirpCursor)
+	resp.Chirps, err = cfg.presentChirps(r.Context(), uuid.NullUUID{UUID: inUID, Valid: true}, chirps)
+	if err != nil {
+		log.Printf("Error preparing chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
 )
 
 type ChirpErrorResponse struct {
@@ -27,3 +31,23 @@ func respondError(w http.ResponseWriter, message string, errorCode int) {
 	dat := generateErrorResp(message)
 	w.Write(dat)
 }
+
+// respondTokenError tells the client why its access token was refused,
+// so it knows whether refreshing the token can help
+func respondTokenError(w http.ResponseWriter, err error) {
+	message := "Authentification failed"
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		message = "Token expired"
+	case errors.Is(err, auth.ErrTokenMalformed):
+		message = "Token malformed"
+	case errors.Is(err, auth.ErrTokenSignatureInvalid):
+		message = "Token signature invalid"
+	case errors.Is(err, auth.ErrTokenInvalidClaims):
+		message = "Token claims invalid"
+	case errors.Is(err, auth.ErrTokenRevoked):
+		message = "Token revoked"
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"invalid_token\", error_description=%q", message))
+	respondError(w, message, http.StatusUnauthorized)
+}
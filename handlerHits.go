@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerHits(w http.ResponseWriter, r *http.Request) {
@@ -19,10 +21,18 @@ func (cfg *apiConfig) handlerHits(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
-	cfg.fileserverHits.Store(0)
-	cfg.handlerHits(w, r)
+	// Deletes every user and everything they made, so it's only allowed on a development setup
+	if cfg.platform != "dev" {
+		logSecurityEvent(r, "reset_denied", uuid.Nil, "platform="+cfg.platform)
+		respondError(w, "Reset is only allowed in development", http.StatusForbidden)
+		return
+	}
 	err := cfg.db.Reset(r.Context())
 	if err != nil {
 		log.Printf("Error resetting the users table: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
 	}
+	cfg.fileserverHits.Store(0)
+	cfg.handlerHits(w, r)
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handlerJWKS publishes the public keys tokens are signed with,
+// so other services can verify them without holding any secret
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	dat, err := json.Marshal(cfg.jwtKeys.JWKS())
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Verifiers may cache the keys for a while, a new signing key should be published before it's used
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// APITokenPrefix starts every personal API token,
+// which tells them apart from JWTs and makes leaked tokens easy to scan for
+const APITokenPrefix = "chirpy_pat_"
+
+// MakeAPIToken generates a new personal API token
+func MakeAPIToken() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + hex.EncodeToString(key), nil
+}
+
+// IsAPIToken reports whether a bearer token is a personal API token rather than a JWT
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken returns the form API tokens are stored and looked up in.
+// The tokens are random, so a fast unsalted hash is enough to make a leaked table useless.
+func HashAPIToken(token string) string {
+	return hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
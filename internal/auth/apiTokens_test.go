@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakeAPIToken(t *testing.T) {
+	token, err := MakeAPIToken()
+	if err != nil {
+		t.Fatalf("Error generating API token: %s", err)
+	}
+	if !IsAPIToken(token) || len(token) != len(APITokenPrefix)+64 {
+		t.Errorf("unexpected token format %q", token)
+	}
+	other, err := MakeAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == other {
+		t.Errorf("tokens should be random")
+	}
+	if IsAPIToken("eyJhbGciOiJIUzI1NiJ9.e30.sig") {
+		t.Errorf("a JWT isn't an API token")
+	}
+}
+
+func TestHashAPIToken(t *testing.T) {
+	token, err := MakeAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := HashAPIToken(token)
+	if hash != HashAPIToken(token) {
+		t.Errorf("hashing should be deterministic")
+	}
+	if strings.Contains(hash, strings.TrimPrefix(token, APITokenPrefix)) || len(hash) != 64 {
+		t.Errorf("unexpected hash %q", hash)
+	}
+}
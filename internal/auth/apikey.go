@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 )
@@ -11,3 +12,9 @@ func GetAPIKey(headers http.Header) string {
 
 	return key
 }
+
+// APIKeyMatches compares a key from a request with the expected one in constant time,
+// so response times don't give the key away. No key matches an empty expected key.
+func APIKeyMatches(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPIKeyMatches(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "ApiKey f271c81ff7084ee5b99a5091b42d486e")
+	key := GetAPIKey(headers)
+
+	if !APIKeyMatches(key, "f271c81ff7084ee5b99a5091b42d486e") {
+		t.Errorf("matching key refused")
+	}
+	if APIKeyMatches(key, "f271c81ff7084ee5b99a5091b42d486f") {
+		t.Errorf("wrong key accepted")
+	}
+	if APIKeyMatches("", "") {
+		t.Errorf("a missing key matches no configured key")
+	}
+}
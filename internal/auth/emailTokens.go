@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// MakeEmailToken generates a token that's sent to a user by e-mail,
+// like a password reset or an e-mail verification token
+func MakeEmailToken() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// HashEmailToken returns the form e-mailed tokens are stored and looked up in
+func HashEmailToken(token string) string {
+	return hashToken(token)
+}
@@ -6,39 +6,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// MakeJWT issues an access token signed with a shared HS256 secret
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-
-	signedStr, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-	return signedStr, nil
+	return NewHMACKeySet(tokenSecret).MakeJWT(userID, expiresIn)
 }
 
+// ValidateJWT checks an access token signed with a shared HS256 secret
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	})
-
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-	uid, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.UUID{}, err
-	}
-
-	return uuid.Parse(uid)
+	return NewHMACKeySet(tokenSecret).ValidateJWT(tokenString)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
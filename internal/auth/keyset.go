@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const minRSABits = 2048
+
+// Key is a key tokens are signed or verified with
+type Key struct {
+	// ID is sent in the kid header of tokens signed with the key
+	ID     string
+	Method jwt.SigningMethod
+	// signKey is nil for keys that only verify
+	signKey   any
+	verifyKey any
+}
+
+// KeySet holds the key new tokens are signed with and all the keys tokens are accepted from.
+// Keeping retired keys around lets tokens signed before a rotation stay valid until they expire.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	opts    ValidationOptions
+}
+
+// NewHMACKeySet creates a key set signing and verifying with a shared HS256 secret
+func NewHMACKeySet(secret string) *KeySet {
+	ks := &KeySet{keys: map[string]*Key{}, opts: DefaultValidationOptions()}
+	ks.AddHMAC(secret)
+	ks.signing = ks.keys[""]
+	return ks
+}
+
+// AddHMAC makes the key set accept HS256 tokens without a kid, signed with a shared secret.
+// It's meant for the transition from a shared secret to asymmetric keys.
+func (ks *KeySet) AddHMAC(secret string) {
+	ks.keys[""] = &Key{
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// LoadKeySet reads the PEM encoded keys of a directory. Every *.pem file holds one key,
+// the file name without the extension is its id. Ed25519 keys are used for EdDSA, RSA keys for RS256.
+// A private key can sign and verify, a public key only verifies,
+// which is enough for keys that were rotated out.
+// activeID picks the signing key, it may be empty if there's a single private key.
+func LoadKeySet(dir, activeID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	ks := &KeySet{keys: map[string]*Key{}, opts: DefaultValidationOptions()}
+	private := []string{}
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := loadKey(id, path)
+		if err != nil {
+			return nil, fmt.Errorf("loading key %s: %w", path, err)
+		}
+		ks.keys[id] = key
+		if key.signKey != nil {
+			private = append(private, id)
+		}
+	}
+
+	if activeID == "" {
+		if len(private) != 1 {
+			return nil, fmt.Errorf("found %d private keys in %s, the signing key must be chosen", len(private), dir)
+		}
+		activeID = private[0]
+	}
+	ks.signing = ks.keys[activeID]
+	if ks.signing == nil || ks.signing.signKey == nil {
+		return nil, fmt.Errorf("no private key %q in %s", activeID, dir)
+	}
+	return ks, nil
+}
+
+func loadKey(id, path string) (*Key, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(dat)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if pub, ok := key.verifyKey.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+	}
+	return key, nil
+}
+
+// SetValidationOptions replaces the checks of ValidateJWT.
+// The issuer and audience are also set on the tokens MakeJWT issues.
+func (ks *KeySet) SetValidationOptions(opts ValidationOptions) {
+	ks.opts = opts
+}
+
+// MakeJWT issues an access token for a user with all scopes, signed with the active key
+func (ks *KeySet) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return ks.MakeAccessToken(Identity{UserID: userID, Scopes: AllScopes}, expiresIn)
+}
+
+// MakeAccessToken issues an access token for the user, scopes and session of an identity.
+// The identity's ExpiresAt is ignored, the token expires after expiresIn.
+func (ks *KeySet) MakeAccessToken(id Identity, expiresIn time.Duration) (string, error) {
+	scope := strings.Join(id.Scopes, " ")
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ks.opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   id.UserID.String(),
+		},
+		Scope: &scope,
+	}
+	if id.SessionID != uuid.Nil {
+		claims.SessionID = id.SessionID.String()
+	}
+	return ks.sign(claims)
+}
+
+// sign signs claims with the active key, setting the audience tokens are validated against
+func (ks *KeySet) sign(claims Claims) (string, error) {
+	if ks.opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{ks.opts.Audience}
+	}
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+
+	signedStr, err := token.SignedString(ks.signing.signKey)
+	if err != nil {
+		return "", err
+	}
+	return signedStr, nil
+}
+
+// ValidateJWT checks an access token and returns the id of the user it was issued to
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	id, err := ks.Authenticate(tokenString)
+	return id.UserID, err
+}
+
+// Authenticate checks an access token and returns who it was issued to and what it allows.
+// Errors are *TokenError values matching ErrTokenExpired, ErrTokenMalformed,
+// ErrTokenSignatureInvalid or ErrTokenInvalidClaims.
+func (ks *KeySet) Authenticate(tokenString string) (Identity, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, ks.keyFunc, ks.opts.parserOptions()...)
+	if err != nil {
+		return Identity{}, classifyTokenError(err)
+	}
+	if err := ks.opts.checkAge(claims); err != nil {
+		return Identity{}, err
+	}
+
+	if claims.Purpose != "" {
+		return Identity{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: fmt.Errorf("%s token is not an access token", claims.Purpose)}
+	}
+	uid, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return Identity{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: fmt.Errorf("token subject is not a user id: %w", err)}
+	}
+	id := Identity{UserID: uid, Scopes: AllScopes, ExpiresAt: claims.ExpiresAt.Time}
+	if claims.Scope != nil {
+		id.Scopes, err = ParseScopes(*claims.Scope)
+		if err != nil {
+			return Identity{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: err}
+		}
+	}
+	if claims.SessionID != "" {
+		id.SessionID, err = uuid.Parse(claims.SessionID)
+		if err != nil {
+			return Identity{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: fmt.Errorf("token session is not a session id: %w", err)}
+		}
+	}
+	return id, nil
+}
+
+// keyFunc finds the key a token claims to be signed with.
+// The token's algorithm has to be the key's, otherwise a public key could be used as an HMAC secret.
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	Alg     string `json:"alg"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS is a set of public keys as served on /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. Shared secrets are never included.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch k := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	slices.SortFunc(set.Keys, func(a, b JWK) int { return strings.Compare(a.KeyID, b.KeyID) })
+	return set
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) {
+	t.Helper()
+	dat := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), dat, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeEd25519Key(t *testing.T, dir, name string) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, name, "PRIVATE KEY", der)
+	return priv
+}
+
+func TestKeySetEd25519(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "2024-01")
+
+	ks, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Error loading key set: %s", err)
+	}
+	id := uuid.New()
+	token, err := ks.MakeJWT(id, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating the jwt: %s", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "2024-01" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("unexpected header %v", parsed.Header)
+	}
+
+	newid, err := ks.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("Error validating jwt: %s", err)
+	}
+	if newid != id {
+		t.Errorf("expected %s, got %s", id, newid)
+	}
+}
+
+func TestKeySetRSA(t *testing.T) {
+	dir := t.TempDir()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "rsa", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))
+
+	ks, err := LoadKeySet(dir, "rsa")
+	if err != nil {
+		t.Fatalf("Error loading key set: %s", err)
+	}
+	id := uuid.New()
+	token, err := ks.MakeJWT(id, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating the jwt: %s", err)
+	}
+	if newid, err := ks.ValidateJWT(token); err != nil || newid != id {
+		t.Errorf("Error validating jwt: %v", err)
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyType != "RSA" || jwks.Keys[0].Alg != "RS256" || jwks.Keys[0].E != "AQAB" {
+		t.Errorf("unexpected jwks %+v", jwks)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldPriv := writeEd25519Key(t, dir, "old")
+	oldKeys, err := LoadKeySet(dir, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := oldKeys.MakeJWT(uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key is retired: only its public half is kept, and a new key signs
+	der, err := x509.MarshalPKIXPublicKey(oldPriv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "old", "PUBLIC KEY", der)
+	writeEd25519Key(t, dir, "new")
+
+	ks, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Error loading key set: %s", err)
+	}
+	if _, err := ks.ValidateJWT(oldToken); err != nil {
+		t.Errorf("tokens of a retired key should still validate: %s", err)
+	}
+	newToken, err := ks.MakeJWT(uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oldKeys.ValidateJWT(newToken); err == nil {
+		t.Errorf("a key set without the new key shouldn't accept its tokens")
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "new" || jwks.Keys[1].KeyID != "old" {
+		t.Errorf("expected both public keys, got %+v", jwks)
+	}
+	for _, k := range jwks.Keys {
+		if k.KeyType != "OKP" || k.Curve != "Ed25519" || k.X == "" {
+			t.Errorf("unexpected jwk %+v", k)
+		}
+	}
+}
+
+func TestKeySetChoosingSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "a")
+	writeEd25519Key(t, dir, "b")
+	if _, err := LoadKeySet(dir, ""); err == nil {
+		t.Errorf("expected an error when the signing key is ambiguous")
+	}
+	if _, err := LoadKeySet(dir, "c"); err == nil {
+		t.Errorf("expected an error for a missing signing key")
+	}
+	if _, err := LoadKeySet(dir, "b"); err != nil {
+		t.Errorf("Error loading key set: %s", err)
+	}
+}
+
+func TestKeySetRejectsForeignTokens(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "main")
+	ks, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signed with a shared secret, but claiming to use the Ed25519 key
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	forged.Header["kid"] = "main"
+	forgedStr, err := forged.SignedString([]byte("SecretCode"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.ValidateJWT(forgedStr); err == nil {
+		t.Errorf("a token with the wrong algorithm for its key should be rejected")
+	}
+
+	// HS256 tokens are only accepted once a secret is added
+	legacy, err := MakeJWT(uuid.New(), "SecretCode", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.ValidateJWT(legacy); err == nil {
+		t.Errorf("HS256 tokens shouldn't be accepted without a secret")
+	}
+	ks.AddHMAC("SecretCode")
+	if _, err := ks.ValidateJWT(legacy); err != nil {
+		t.Errorf("Error validating HS256 token: %s", err)
+	}
+	if len(ks.JWKS().Keys) != 1 {
+		t.Errorf("shared secrets must never be published")
+	}
+}
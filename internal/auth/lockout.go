@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginBackoff decides how long logins are refused after failed attempts.
+// Past the free attempts, every failure doubles the wait, up to Max, which amounts to a lockout.
+type LoginBackoff struct {
+	// FreeAttempts is how many failures are allowed before any wait
+	FreeAttempts int
+	// Base is the wait after the first failure past the free attempts
+	Base time.Duration
+	// Max is the longest wait
+	Max time.Duration
+}
+
+// Delay is how long logins are refused after the given number of consecutive failures
+func (b LoginBackoff) Delay(failures int) time.Duration {
+	over := failures - b.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := b.Base
+	for i := 1; i < over && delay < b.Max; i++ {
+		delay *= 2
+	}
+	return min(delay, b.Max)
+}
+
+// LockedUntil is when logins are allowed again after the given number of failures, the last one at lastFailure.
+// It's the zero time if no wait is needed.
+func (b LoginBackoff) LockedUntil(failures int, lastFailure time.Time) time.Time {
+	delay := b.Delay(failures)
+	if delay == 0 {
+		return time.Time{}
+	}
+	return lastFailure.Add(delay)
+}
+
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := HashPassword("chirpy dummy password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// CheckNoPasswordHash takes as long as CheckPasswordHash, and always fails.
+// It's for logins of unknown users, so response times don't tell which accounts exist.
+func CheckNoPasswordHash(password string) {
+	CheckPasswordHash(password, dummyPasswordHash())
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginBackoffDelay(t *testing.T) {
+	b := LoginBackoff{FreeAttempts: 3, Base: 30 * time.Second, Max: 10 * time.Minute}
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, 30 * time.Second},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{8, 8 * time.Minute},
+		{9, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, c := range cases {
+		if got := b.Delay(c.failures); got != c.want {
+			t.Errorf("Delay(%d) = %s, want %s", c.failures, got, c.want)
+		}
+	}
+}
+
+func TestLoginBackoffLockedUntil(t *testing.T) {
+	b := LoginBackoff{FreeAttempts: 1, Base: time.Minute, Max: time.Hour}
+	last := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if got := b.LockedUntil(1, last); !got.IsZero() {
+		t.Errorf("free attempt locked until %s", got)
+	}
+	if got := b.LockedUntil(3, last); !got.Equal(last.Add(2 * time.Minute)) {
+		t.Errorf("got %s, want %s", got, last.Add(2*time.Minute))
+	}
+}
+
+func TestCheckNoPasswordHash(t *testing.T) {
+	// Only has to run without panicking, its point is to take time
+	CheckNoPasswordHash("hunter2")
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// PurposeMFAChallenge marks the tokens handed out after the password step of a login with 2FA
+const PurposeMFAChallenge = "mfa"
+
+// MFAChallenge is what a valid MFA challenge token says
+type MFAChallenge struct {
+	// ID tells challenges apart, so the codes tried against each can be counted
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+// MakeMFAChallenge issues a token proving the user got their password right.
+// It's exchanged, together with a second factor, for an access token; it can't be used as one.
+func (ks *KeySet) MakeMFAChallenge(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return ks.sign(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ks.opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+		Purpose: PurposeMFAChallenge,
+	})
+}
+
+// ValidateMFAChallenge checks an MFA challenge token and returns who it was issued to.
+// Errors are *TokenError values, like those of Authenticate.
+func (ks *KeySet) ValidateMFAChallenge(tokenString string) (MFAChallenge, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, ks.keyFunc, ks.opts.parserOptions()...)
+	if err != nil {
+		return MFAChallenge{}, classifyTokenError(err)
+	}
+	if claims.Purpose != PurposeMFAChallenge {
+		return MFAChallenge{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: fmt.Errorf("not an MFA challenge token")}
+	}
+	uid, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return MFAChallenge{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: fmt.Errorf("token subject is not a user id: %w", err)}
+	}
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return MFAChallenge{}, &TokenError{Kind: ErrTokenInvalidClaims, Err: fmt.Errorf("token id is not a uuid: %w", err)}
+	}
+	return MFAChallenge{ID: id, UserID: uid, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
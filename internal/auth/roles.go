@@ -0,0 +1,32 @@
+package auth
+
+import "fmt"
+
+// Role is what a user may do beyond using their own account.
+// Every role can do what the roles below it can.
+type Role string
+
+const (
+	RoleUser Role = "user"
+	// RoleModerator reviews flagged chirps and manages the moderation word list
+	RoleModerator Role = "moderator"
+	// RoleAdmin can use every admin endpoint
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
+// ParseRole reads a role as stored in the database or sent in a request
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// AtLeast reports whether the role can do what required can. Unknown roles can't do anything.
+func (r Role) AtLeast(required Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[required]
+}
@@ -0,0 +1,39 @@
+package auth
+
+import "testing"
+
+func TestParseRole(t *testing.T) {
+	for _, s := range []string{"user", "moderator", "admin"} {
+		role, err := ParseRole(s)
+		if err != nil || string(role) != s {
+			t.Errorf("ParseRole(%q) = %q, %v", s, role, err)
+		}
+	}
+	for _, s := range []string{"", "Admin", "root"} {
+		if _, err := ParseRole(s); err == nil {
+			t.Errorf("ParseRole(%q) succeeded", s)
+		}
+	}
+}
+
+func TestRoleAtLeast(t *testing.T) {
+	cases := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleUser, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleModerator, RoleModerator, true},
+		{RoleUser, RoleModerator, false},
+		{RoleUser, RoleUser, true},
+		{Role("root"), RoleUser, false},
+	}
+	for _, c := range cases {
+		if got := c.role.AtLeast(c.required); got != c.want {
+			t.Errorf("%q.AtLeast(%q) = %v, want %v", c.role, c.required, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Scopes limit what an access token can be used for
+const (
+	// ScopeRead allows reading what's only visible to the user, like the timeline and notifications
+	ScopeRead = "read"
+	// ScopeChirpsWrite allows posting, editing and deleting chirps, and liking and rechirping
+	ScopeChirpsWrite = "chirps:write"
+	// ScopeAccountWrite allows changing the account, who it follows and which notifications are read
+	ScopeAccountWrite = "account:write"
+)
+
+// AllScopes are granted to tokens issued at login
+var AllScopes = []string{ScopeRead, ScopeChirpsWrite, ScopeAccountWrite}
+
+// ParseScopes reads a space separated list of scopes, as found in the scope claim
+func ParseScopes(s string) ([]string, error) {
+	scopes := []string{}
+	for _, scope := range strings.Fields(s) {
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// HasScopes reports whether all the required scopes were granted
+func HasScopes(granted []string, required ...string) bool {
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Claims are the claims of chirpy access tokens
+type Claims struct {
+	jwt.RegisteredClaims
+	// Scope is a space separated list of scopes.
+	// Tokens issued before scopes existed don't have it, they're granted all scopes.
+	Scope *string `json:"scope,omitempty"`
+	// SessionID is the login the token was issued for, tokens of API tokens have none
+	SessionID string `json:"sid,omitempty"`
+	// Purpose marks tokens that aren't access tokens, like MFA challenges
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// Identity is who an access token was issued to, and what it allows
+type Identity struct {
+	UserID    uuid.UUID
+	Scopes    []string
+	ExpiresAt time.Time
+	// APIToken is set when the identity comes from a personal API token rather than a JWT
+	APIToken bool
+	// SessionID is uuid.Nil for tokens that don't belong to a login session
+	SessionID uuid.UUID
+}
+
+// HasScopes reports whether the token grants all the required scopes
+func (id Identity) HasScopes(required ...string) bool {
+	return HasScopes(id.Scopes, required...)
+}
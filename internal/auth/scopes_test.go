@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("read  chirps:write read")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Equal(scopes, []string{"read", "chirps:write"}) {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+	if _, err := ParseScopes("read admin"); err == nil {
+		t.Errorf("expected an error for an unknown scope")
+	}
+	if scopes, err := ParseScopes(""); err != nil || len(scopes) != 0 {
+		t.Errorf("expected no scopes, got %v, %v", scopes, err)
+	}
+}
+
+func TestScopedJWT(t *testing.T) {
+	ks := NewHMACKeySet("SecretCode")
+	uid := uuid.New()
+
+	sid := uuid.New()
+	token, err := ks.MakeAccessToken(Identity{UserID: uid, Scopes: []string{ScopeRead}, SessionID: sid}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := ks.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Error authenticating: %s", err)
+	}
+	if id.UserID != uid || id.SessionID != sid || !id.HasScopes(ScopeRead) || id.HasScopes(ScopeRead, ScopeChirpsWrite) {
+		t.Errorf("unexpected identity %+v", id)
+	}
+	if time.Until(id.ExpiresAt) > time.Minute {
+		t.Errorf("unexpected expiry %s", id.ExpiresAt)
+	}
+
+	token, err = ks.MakeAccessToken(Identity{UserID: uid, Scopes: []string{}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := ks.Authenticate(token); err != nil || len(id.Scopes) != 0 || id.SessionID != uuid.Nil {
+		t.Errorf("a token without scopes or session shouldn't get any, got %+v, %v", id, err)
+	}
+
+	// Tokens from before scopes existed have no scope claim at all
+	legacy := signHS256(t, "SecretCode", validClaims())
+	if id, err := ks.Authenticate(legacy); err != nil || !id.HasScopes(AllScopes...) {
+		t.Errorf("expected all scopes for a token without a scope claim, got %+v, %v", id, err)
+	}
+}
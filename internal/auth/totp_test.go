@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/base32"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestHOTPVectors(t *testing.T) {
+	// Test vectors of RFC 6238 appendix B, for SHA1
+	key := []byte("12345678901234567890")
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, c := range cases {
+		got := hotp(key, uint64(TOTPStep(time.Unix(c.unix, 0))), 8)
+		if got != c.want {
+			t.Errorf("at %d: got %s, want %s", c.unix, got, c.want)
+		}
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	code, err := TOTPCode(secret, time.Unix(1111111109, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "081804" {
+		t.Errorf("got %s, want 081804", code)
+	}
+	if _, err := TOTPCode("not base32!", time.Now()); err == nil {
+		t.Errorf("expected an error for an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 10, 0, time.UTC)
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	if !ok || step != TOTPStep(now) {
+		t.Fatalf("valid code refused")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(TOTPPeriod), 0); !ok {
+		t.Errorf("code of the previous period should be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(-TOTPPeriod), 0); !ok {
+		t.Errorf("code of the next period should be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(3*TOTPPeriod), 0); ok {
+		t.Errorf("stale code accepted")
+	}
+	if _, ok := ValidateTOTP(secret, code, now, step); ok {
+		t.Errorf("used code accepted again")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now, 0); ok {
+		t.Errorf("short code accepted")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "walt@example.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("unexpected uri %s", uri)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Chirpy" || q.Get("digits") != "6" {
+		t.Errorf("unexpected parameters %s", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 9 || code[4] != '-' {
+			t.Errorf("unexpected code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+		if NormalizeRecoveryCode(" "+typed+" ") != code {
+			t.Errorf("%q doesn't normalize to %q", typed, code)
+		}
+		if !IsRecoveryCode(code) {
+			t.Errorf("%q isn't taken for a recovery code", code)
+		}
+	}
+	for _, code := range []string{"123456", "", "abcd-efg", "abcd-efgh1", "abcd_efgh", "abc1-efgh"} {
+		if IsRecoveryCode(NormalizeRecoveryCode(code)) {
+			t.Errorf("%q is taken for a recovery code", code)
+		}
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	ks := NewHMACKeySet("secret")
+	userID := uuid.New()
+
+	challenge, err := ks.MakeMFAChallenge(userID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ks.ValidateMFAChallenge(challenge)
+	if err != nil || got.UserID != userID {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	other, err := ks.MakeMFAChallenge(userID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherGot, err := ks.ValidateMFAChallenge(other); err != nil || otherGot.ID == got.ID {
+		t.Errorf("challenges share an id: %+v, %v", otherGot, err)
+	}
+	if _, err := ks.Authenticate(challenge); !errors.Is(err, ErrTokenInvalidClaims) {
+		t.Errorf("challenge accepted as an access token: %v", err)
+	}
+
+	access, err := ks.MakeJWT(userID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.ValidateMFAChallenge(access); !errors.Is(err, ErrTokenInvalidClaims) {
+		t.Errorf("access token accepted as a challenge: %v", err)
+	}
+
+	expired, err := ks.MakeMFAChallenge(userID, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.ValidateMFAChallenge(expired); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+}
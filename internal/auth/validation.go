@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The kinds of reasons a token can be refused for.
+// Errors returned by ValidateJWT match one of them with errors.Is.
+// ErrTokenRevoked is for tokens checked against the database, like API tokens.
+var (
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenMalformed        = errors.New("token malformed")
+	ErrTokenSignatureInvalid = errors.New("token signature invalid")
+	ErrTokenInvalidClaims    = errors.New("token claims invalid")
+	ErrTokenRevoked          = errors.New("token unknown or revoked")
+)
+
+// TokenError is a refused token. It keeps the message of the underlying error,
+// and matches its kind as well as the underlying error with errors.Is.
+type TokenError struct {
+	Kind error
+	Err  error
+}
+
+func (e *TokenError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TokenError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// ValidationOptions are the checks tokens have to pass besides their signature and expiry
+type ValidationOptions struct {
+	// Algorithms lists the accepted signing algorithms. If empty, any algorithm of a known key is accepted,
+	// a token still has to use the algorithm of the key it names.
+	Algorithms []string
+	// Issuer is the required iss claim, it's set on issued tokens too. Not checked if empty.
+	Issuer string
+	// Audience is the required aud claim, it's set on issued tokens too. Not checked if empty.
+	Audience string
+	// Leeway is the clock skew tolerated on expiry and issue times
+	Leeway time.Duration
+	// MaxAge refuses tokens issued longer ago, whatever their expiry. Zero means no limit.
+	MaxAge time.Duration
+}
+
+// DefaultValidationOptions only accept tokens issued by chirpy
+func DefaultValidationOptions() ValidationOptions {
+	return ValidationOptions{Issuer: "chirpy"}
+}
+
+func (o ValidationOptions) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(o.Leeway),
+	}
+	if len(o.Algorithms) > 0 {
+		opts = append(opts, jwt.WithValidMethods(o.Algorithms))
+	}
+	if o.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(o.Issuer))
+	}
+	if o.Audience != "" {
+		opts = append(opts, jwt.WithAudience(o.Audience))
+	}
+	return opts
+}
+
+// checkAge enforces MaxAge on the claims of a token that's otherwise valid
+func (o ValidationOptions) checkAge(claims jwt.Claims) error {
+	if o.MaxAge == 0 {
+		return nil
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return &TokenError{Kind: ErrTokenInvalidClaims, Err: errors.New("token has no issue time")}
+	}
+	if time.Since(issuedAt.Time) > o.MaxAge+o.Leeway {
+		return &TokenError{Kind: ErrTokenExpired, Err: fmt.Errorf("token is older than %s", o.MaxAge)}
+	}
+	return nil
+}
+
+// classifyTokenError sorts the errors of the jwt library into the kinds handlers care about
+func classifyTokenError(err error) error {
+	kind := ErrTokenInvalidClaims
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		kind = ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		kind = ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		kind = ErrTokenSignatureInvalid
+	}
+	return &TokenError{Kind: kind, Err: err}
+}
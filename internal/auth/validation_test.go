@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func signHS256(t *testing.T, secret string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Subject:   uuid.New().String(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestValidateJWTErrors(t *testing.T) {
+	ks := NewHMACKeySet("SecretCode")
+
+	expired := validClaims()
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+
+	badSubject := validClaims()
+	badSubject.Subject = "not-a-uuid"
+
+	cases := []struct {
+		name  string
+		token string
+		kind  error
+	}{
+		{"expired", signHS256(t, "SecretCode", expired), ErrTokenExpired},
+		{"malformed", "not.a.token", ErrTokenMalformed},
+		{"wrong secret", signHS256(t, "OtherCode", validClaims()), ErrTokenSignatureInvalid},
+		{"wrong issuer", signHS256(t, "SecretCode", wrongIssuer), ErrTokenInvalidClaims},
+		{"no expiry", signHS256(t, "SecretCode", noExpiry), ErrTokenInvalidClaims},
+		{"bad subject", signHS256(t, "SecretCode", badSubject), ErrTokenInvalidClaims},
+	}
+	for _, c := range cases {
+		_, err := ks.ValidateJWT(c.token)
+		if !errors.Is(err, c.kind) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.kind, err)
+		}
+	}
+
+	// The messages of the jwt library are kept
+	_, err := ks.ValidateJWT(signHS256(t, "SecretCode", expired))
+	if err.Error() != "token has invalid claims: token is expired" || !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("unexpected error %q", err)
+	}
+}
+
+func TestValidationOptions(t *testing.T) {
+	ks := NewHMACKeySet("SecretCode")
+	ks.SetValidationOptions(ValidationOptions{
+		Algorithms: []string{"HS256"},
+		Issuer:     "chirpy",
+		Audience:   "chirpy-api",
+		Leeway:     30 * time.Second,
+		MaxAge:     time.Hour,
+	})
+
+	// Tokens of the key set carry the audience
+	token, err := ks.MakeJWT(uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.ValidateJWT(token); err != nil {
+		t.Errorf("Error validating jwt: %s", err)
+	}
+
+	noAudience := validClaims()
+	if _, err := ks.ValidateJWT(signHS256(t, "SecretCode", noAudience)); !errors.Is(err, ErrTokenInvalidClaims) {
+		t.Errorf("expected a missing audience to be refused, got %v", err)
+	}
+
+	withinLeeway := validClaims()
+	withinLeeway.Audience = jwt.ClaimStrings{"chirpy-api"}
+	withinLeeway.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+	if _, err := ks.ValidateJWT(signHS256(t, "SecretCode", withinLeeway)); err != nil {
+		t.Errorf("expected a token expired within the leeway to pass, got %v", err)
+	}
+
+	tooOld := validClaims()
+	tooOld.Audience = jwt.ClaimStrings{"chirpy-api"}
+	tooOld.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))
+	tooOld.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	if _, err := ks.ValidateJWT(signHS256(t, "SecretCode", tooOld)); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected a token older than the max age to be refused, got %v", err)
+	}
+
+	// Algorithms not on the list are refused even with a matching key
+	ks.SetValidationOptions(ValidationOptions{Algorithms: []string{"EdDSA"}})
+	if _, err := ks.ValidateJWT(token); !errors.Is(err, ErrTokenSignatureInvalid) {
+		t.Errorf("expected HS256 to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const maxTagLength = 100
+
+// A hashtag starts with # that isn't glued to a preceding word,
+// so "#go" is a tag but "c#" or "issue#12" aren't
+var hashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+// NormalizeTag turns a tag as typed by the user into the form it's indexed by
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// ExtractHashtags returns the distinct, normalized hashtags of a chirp body, in order of appearance.
+// Tags made of digits and underscores only, like #1, are not hashtags.
+func ExtractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagRe.FindAllStringSubmatch(body, -1) {
+		tag := NormalizeTag(match[1])
+		if seen[tag] || len(tag) > maxTagLength || !strings.ContainsFunc(tag, unicode.IsLetter) {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
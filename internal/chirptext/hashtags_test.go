@@ -0,0 +1,38 @@
+package chirptext
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", []string{}},
+		{"#Go is fun", []string{"go"}},
+		{"learning #golang, #SQL and #golang again", []string{"golang", "sql"}},
+		{"tags (#inside) parens!#after", []string{"inside", "after"}},
+		{"c# and issue#12 aren't tags", []string{}},
+		{"#123 is a number, #v2 is a tag", []string{"v2"}},
+		{"unicode #zażółć #自分", []string{"zażółć", "自分"}},
+		{"masked #**** tag", []string{}},
+		{"##double", []string{}},
+	}
+	for _, c := range cases {
+		got := ExtractHashtags(c.body)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("ExtractHashtags(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	if tag := NormalizeTag("#GoLang"); tag != "golang" {
+		t.Errorf("expected golang, got %s", tag)
+	}
+	if tag := NormalizeTag("golang"); tag != "golang" {
+		t.Errorf("expected golang, got %s", tag)
+	}
+}
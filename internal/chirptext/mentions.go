@@ -0,0 +1,46 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	usernameRe = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+	// Like hashtags, a mention can't be glued to a preceding word, so e-mail addresses don't count
+	mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])(@[A-Za-z0-9_]{3,30})\b`)
+)
+
+// Mention is an @username token in a chirp body.
+// Start and End are offsets in characters (code points), End is exclusive.
+type Mention struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// ValidUsername reports if a username is 3 to 30 letters, digits or underscores
+func ValidUsername(username string) bool {
+	return usernameRe.MatchString(username)
+}
+
+// NormalizeUsername is the form usernames are compared in, they're case insensitive
+func NormalizeUsername(username string) string {
+	return strings.ToLower(username)
+}
+
+// ExtractMentions returns every @username token of a chirp body, in order of appearance
+func ExtractMentions(body string) []Mention {
+	mentions := []Mention{}
+	for _, loc := range mentionRe.FindAllStringSubmatchIndex(body, -1) {
+		start, end := loc[2], loc[3]
+		runeStart := utf8.RuneCountInString(body[:start])
+		mentions = append(mentions, Mention{
+			Username: body[start+1 : end],
+			Start:    runeStart,
+			End:      runeStart + utf8.RuneCountInString(body[start:end]),
+		})
+	}
+	return mentions
+}
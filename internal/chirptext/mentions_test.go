@@ -0,0 +1,40 @@
+package chirptext
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	cases := []struct {
+		body string
+		want []Mention
+	}{
+		{"nobody here", []Mention{}},
+		{"@alice hi", []Mention{{"alice", 0, 6}}},
+		{"hi @Bob_1 and @carol!", []Mention{{"Bob_1", 3, 9}, {"carol", 14, 20}}},
+		{"mail me at me@example.com", []Mention{}},
+		{"too short @ab", []Mention{}},
+		{"żółw @dave", []Mention{{"dave", 5, 10}}},
+		{"@@eve", []Mention{}},
+	}
+	for _, c := range cases {
+		got := ExtractMentions(c.body)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("ExtractMentions(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestValidUsername(t *testing.T) {
+	for _, name := range []string{"bob", "Alice_99", "a_very_long_but_valid_name_30c"} {
+		if !ValidUsername(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"", "ab", "with space", "zażółć", "@bob", "a_name_that_is_way_too_long_to_be_valid"} {
+		if ValidUsername(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
@@ -7,21 +7,66 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const addChirpLikes = `-- name: AddChirpLikes :one
+UPDATE chirps SET like_count = like_count + $1::integer WHERE id = $2 RETURNING like_count
+`
+
+type AddChirpLikesParams struct {
+	Delta int32     `json:"delta"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func (q *Queries) AddChirpLikes(ctx context.Context, arg AddChirpLikesParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, addChirpLikes, arg.Delta, arg.ID)
+	var like_count int32
+	err := row.Scan(&like_count)
+	return like_count, err
+}
+
+const countRecentChirps = `-- name: CountRecentChirps :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND created_at > NOW() - INTERVAL '1 hour'
+`
+
+func (q *Queries) CountRecentChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (created_at, updated_at, body, user_id) VALUES (NOW(), NOW(), $1, $2) RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, quote_of, ancestor_ids)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5, $6, $7, COALESCE($8::uuid[], '{}')) RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector
 `
 
 type CreateChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	ID          uuid.UUID     `json:"id"`
+	Body        string        `json:"body"`
+	UserID      uuid.UUID     `json:"user_id"`
+	InReplyTo   uuid.NullUUID `json:"in_reply_to"`
+	ThreadID    uuid.UUID     `json:"thread_id"`
+	RechirpOf   uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf     uuid.NullUUID `json:"quote_of"`
+	AncestorIds []uuid.UUID   `json:"ancestor_ids"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ID,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.ThreadID,
+		arg.RechirpOf,
+		arg.QuoteOf,
+		pq.Array(arg.AncestorIds),
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -29,12 +74,19 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		pq.Array(&i.AncestorIds),
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :one
-DELETE FROM chirps WHERE id = $1 AND user_id = $2 RETURNING id, created_at, updated_at, body, user_id
+DELETE FROM chirps WHERE id = $1 AND user_id = $2 RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector
 `
 
 type DeleteChirpParams struct {
@@ -51,12 +103,68 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		pq.Array(&i.AncestorIds),
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps parent
+    WHERE parent.id = (SELECT child.in_reply_to FROM chirps child WHERE child.id = $1)
+    UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+    FROM chirps parent JOIN ancestors ON parent.id = ancestors.in_reply_to
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.thread_id, chirps.ancestor_ids, chirps.like_count, chirps.rechirp_of, chirps.quote_of, chirps.search_vector FROM chirps JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC
+`
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			pq.Array(&i.AncestorIds),
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -68,16 +176,194 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		pq.Array(&i.AncestorIds),
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getChirpByIdForUpdate = `-- name: GetChirpByIdForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetChirpByIdForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIdForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		pq.Array(&i.AncestorIds),
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps
+WHERE ancestor_ids @> ARRAY[$1::uuid]
+    AND (created_at, id) > ($2::timestamp, $3::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetChirpDescendantsParams struct {
+	ChirpID        uuid.UUID `json:"chirp_id"`
+	AfterCreatedAt time.Time `json:"after_created_at"`
+	AfterID        uuid.UUID `json:"after_id"`
+	MaxRows        int32     `json:"max_rows"`
+}
+
+// Replies below deleted chirps are still found through ancestor_ids
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants,
+		arg.ChirpID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			pq.Array(&i.AncestorIds),
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			pq.Array(&i.AncestorIds),
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirp = `-- name: GetRechirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps WHERE user_id = $1 AND rechirp_of = $2
+`
+
+type GetRechirpParams struct {
+	UserID    uuid.UUID     `json:"user_id"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		pq.Array(&i.AncestorIds),
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1)
+    AND (created_at, id) > ($2::timestamp, $3::uuid)
+    AND (created_at, id) < ($4::timestamp, $5::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $6
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID `json:"author_id"`
+	AfterCreatedAt  time.Time     `json:"after_created_at"`
+	AfterID         uuid.UUID     `json:"after_id"`
+	BeforeCreatedAt time.Time     `json:"before_created_at"`
+	BeforeID        uuid.UUID     `json:"before_id"`
+	MaxRows         int32         `json:"max_rows"`
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.MaxRows,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +377,13 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			pq.Array(&i.AncestorIds),
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -105,12 +398,33 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsForUser = `-- name: GetChirpsForUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1)
+    AND (created_at, id) > ($2::timestamp, $3::uuid)
+    AND (created_at, id) < ($4::timestamp, $5::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT $6
 `
 
-func (q *Queries) GetChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsForUser, userID)
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID `json:"author_id"`
+	AfterCreatedAt  time.Time     `json:"after_created_at"`
+	AfterID         uuid.UUID     `json:"after_id"`
+	BeforeCreatedAt time.Time     `json:"before_created_at"`
+	BeforeID        uuid.UUID     `json:"before_id"`
+	MaxRows         int32         `json:"max_rows"`
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.MaxRows,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +438,13 @@ func (q *Queries) GetChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chi
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ThreadID,
+			pq.Array(&i.AncestorIds),
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +458,32 @@ func (q *Queries) GetChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chi
 	}
 	return items, nil
 }
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, body, user_id, in_reply_to, thread_id, ancestor_ids, like_count, rechirp_of, quote_of, search_vector
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ThreadID,
+		pq.Array(&i.AncestorIds),
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.SearchVector,
+	)
+	return i, err
+}
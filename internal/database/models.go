@@ -11,28 +11,220 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"token_hash"`
+	Scopes     []string     `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
 type Chirp struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	UserID       uuid.UUID     `json:"user_id"`
+	InReplyTo    uuid.NullUUID `json:"in_reply_to"`
+	ThreadID     uuid.UUID     `json:"thread_id"`
+	AncestorIds  []uuid.UUID   `json:"ancestor_ids"`
+	LikeCount    int32         `json:"like_count"`
+	RechirpOf    uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf      uuid.NullUUID `json:"quote_of"`
+	SearchVector interface{}   `json:"search_vector"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type ChirpTag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	TagID     uuid.UUID `json:"tag_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type EmailVerification struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Email     string       `json:"email"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Like struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginFailure struct {
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+type Mention struct {
+	ChirpID     uuid.UUID `json:"chirp_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	StartOffset int32     `json:"start_offset"`
+	EndOffset   int32     `json:"end_offset"`
+}
+
+type MfaChallenge struct {
 	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int32     `json:"attempts"`
+}
+
+type ModerationFlag struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ChirpID    uuid.UUID    `json:"chirp_id"`
+	Words      []string     `json:"words"`
+	ReviewedAt sql.NullTime `json:"reviewed_at"`
+}
+
+type ModerationWord struct {
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
 }
 
-type RefreshToken struct {
-	Token     string       `json:"token"`
+type Notification struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Kind      string        `json:"kind"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	ReadAt    sql.NullTime  `json:"read_at"`
+}
+
+type PasswordReset struct {
+	ID        uuid.UUID    `json:"id"`
 	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
 	UserID    uuid.UUID    `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
 	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	Token      string         `json:"token"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	UserID     uuid.UUID      `json:"user_id"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+	FamilyID   uuid.UUID      `json:"family_id"`
+	ReplacedBy sql.NullString `json:"replaced_by"`
+}
+
+type Session struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	UserAgent  string       `json:"user_agent"`
+	Ip         string       `json:"ip"`
+	LastUsedAt time.Time    `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type SubscriptionEvent struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Source      string         `json:"source"`
+	EventID     sql.NullString `json:"event_id"`
+	Event       string         `json:"event"`
+	OldStatus   string         `json:"old_status"`
+	NewStatus   string         `json:"new_status"`
+	Plan        string         `json:"plan"`
+	PeriodEnd   sql.NullTime   `json:"period_end"`
+	IsChirpyRed bool           `json:"is_chirpy_red"`
+	Note        string         `json:"note"`
+}
+
+type Tag struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID                    uuid.UUID      `json:"id"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	Email                 string         `json:"email"`
+	HashedPassword        string         `json:"hashed_password"`
+	IsChirpyRed           bool           `json:"is_chirpy_red"`
+	Username              sql.NullString `json:"username"`
+	TotpSecret            sql.NullString `json:"totp_secret"`
+	TotpEnabledAt         sql.NullTime   `json:"totp_enabled_at"`
+	TotpLastStep          int64          `json:"totp_last_step"`
+	EmailVerifiedAt       sql.NullTime   `json:"email_verified_at"`
+	SubscriptionStatus    string         `json:"subscription_status"`
+	SubscriptionPlan      string         `json:"subscription_plan"`
+	SubscriptionPeriodEnd sql.NullTime   `json:"subscription_period_end"`
+	Role                  string         `json:"role"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	EndpointID     uuid.UUID      `json:"endpoint_id"`
+	EventID        uuid.UUID      `json:"event_id"`
+	Event          string         `json:"event"`
+	Payload        string         `json:"payload"`
+	Status         string         `json:"status"`
+	Attempts       int32          `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  sql.NullTime   `json:"last_attempt_at"`
+	LastStatusCode sql.NullInt32  `json:"last_status_code"`
+	LastError      sql.NullString `json:"last_error"`
+	DeliveredAt    sql.NullTime   `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Url       string        `json:"url"`
+	Secret    string        `json:"secret"`
+	Events    []string      `json:"events"`
+}
+
+type WebhookEvent struct {
+	Source     string    `json:"source"`
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	ReceivedAt time.Time `json:"received_at"`
 }
@@ -7,13 +7,14 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const getRefToken = `-- name: GetRefToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE token = $1
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by FROM refresh_tokens WHERE token = $1
 `
 
 func (q *Queries) GetRefToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -26,6 +27,28 @@ func (q *Queries) GetRefToken(ctx context.Context, token string) (RefreshToken,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
+	)
+	return i, err
+}
+
+const getRefTokenForUpdate = `-- name: GetRefTokenForUpdate :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by FROM refresh_tokens WHERE token = $1 FOR UPDATE
+`
+
+func (q *Queries) GetRefTokenForUpdate(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefTokenForUpdate, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
@@ -41,8 +64,51 @@ func (q *Queries) GetUserFromRefToken(ctx context.Context, token string) (uuid.U
 	return user_id, err
 }
 
+const replaceRefToken = `-- name: ReplaceRefToken :one
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW(), replaced_by = $2 WHERE token = $1 RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by
+`
+
+type ReplaceRefTokenParams struct {
+	Token      string         `json:"token"`
+	ReplacedBy sql.NullString `json:"replaced_by"`
+}
+
+func (q *Queries) ReplaceRefToken(ctx context.Context, arg ReplaceRefTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, replaceRefToken, arg.Token, arg.ReplacedBy)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
+	)
+	return i, err
+}
+
+const revokeRefTokenFamily = `-- name: RevokeRefTokenFamily :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeRefTokenFamilyParams struct {
+	FamilyID uuid.UUID `json:"family_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+// Family ids are session ids, which clients know, so the user has to be given too
+func (q *Queries) RevokeRefTokenFamily(ctx context.Context, arg RevokeRefTokenFamilyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefTokenFamily, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeToken = `-- name: RevokeToken :one
-UPDATE refresh_tokens SET revoked_at = Now(), updated_at = Now() WHERE token = $1 RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+UPDATE refresh_tokens SET revoked_at = Now(), updated_at = Now() WHERE token = $1 RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by
 `
 
 func (q *Queries) RevokeToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -55,23 +121,31 @@ func (q *Queries) RevokeToken(ctx context.Context, token string) (RefreshToken,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
 
 const setRefToken = `-- name: SetRefToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at,        revoked_at) VALUES 
-                              ($1,      NOW(),      NOW(),      $2, $3, NULL      ) RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at,        revoked_at, family_id) VALUES 
+                              ($1,      NOW(),      NOW(),      $2, $3, NULL      , $4) RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by
 `
 
 type SetRefTokenParams struct {
 	Token     string    `json:"token"`
 	UserID    uuid.UUID `json:"user_id"`
 	ExpiresAt time.Time `json:"expires_at"`
+	FamilyID  uuid.UUID `json:"family_id"`
 }
 
 func (q *Queries) SetRefToken(ctx context.Context, arg SetRefTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, setRefToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, setRefToken,
+		arg.Token,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.FamilyID,
+	)
 	var i RefreshToken
 	err := row.Scan(
 		&i.Token,
@@ -80,6 +154,8 @@ func (q *Queries) SetRefToken(ctx context.Context, arg SetRefTokenParams) (Refre
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
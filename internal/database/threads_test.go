@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// testQueries connects to the migrated database in CHIRPY_TEST_DB_URL.
+// Everything a test does is rolled back when it ends.
+func testQueries(t *testing.T) *Queries {
+	t.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return New(tx)
+}
+
+func TestGetChirpDescendantsBelowDeletedChirp(t *testing.T) {
+	ctx := context.Background()
+	q := testQueries(t)
+
+	user, err := q.CreateUser(ctx, CreateUserParams{Email: uuid.NewString() + "@example.com", HashedPassword: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := func(parent *Chirp) Chirp {
+		t.Helper()
+		params := CreateChirpParams{ID: uuid.New(), Body: "chirp", UserID: user.ID}
+		params.ThreadID = params.ID
+		if parent != nil {
+			params.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+			params.ThreadID = parent.ThreadID
+			params.AncestorIds = append(parent.AncestorIds, parent.ID)
+		}
+		chirp, err := q.CreateChirp(ctx, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chirp
+	}
+
+	// root <- middle <- leaf, then middle is deleted
+	root := reply(nil)
+	middle := reply(&root)
+	leaf := reply(&middle)
+	if _, err := q.DeleteChirp(ctx, DeleteChirpParams{ID: middle.ID, UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	descendants, err := q.GetChirpDescendants(ctx, GetChirpDescendantsParams{
+		ChirpID:        root.ID,
+		AfterCreatedAt: time.Time{},
+		AfterID:        uuid.Nil,
+		MaxRows:        10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descendants) != 1 || descendants[0].ID != leaf.ID {
+		t.Fatalf("descendants of root = %v, want only the leaf %s", descendants, leaf.ID)
+	}
+}
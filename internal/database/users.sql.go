@@ -7,21 +7,35 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countAdmins = `-- name: CountAdmins :one
+SELECT COUNT(*) FROM users WHERE role = 'admin'
+`
+
+func (q *Queries) CountAdmins(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAdmins)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (created_at, updated_at, email, hashed_password) VALUES (NOW(), NOW(), $1, $2) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+INSERT INTO users (created_at, updated_at, email, hashed_password, username) VALUES (NOW(), NOW(), $1, $2, $3) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, subscription_status, subscription_plan, subscription_period_end, role
 `
 
 type CreateUserParams struct {
-	Email          string `json:"email"`
-	HashedPassword string `json:"hashed_password"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	Username       sql.NullString `json:"username"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Username)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -30,12 +44,21 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.SubscriptionStatus,
+		&i.SubscriptionPlan,
+		&i.SubscriptionPeriodEnd,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, subscription_status, subscription_plan, subscription_period_end, role FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -48,12 +71,21 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.SubscriptionStatus,
+		&i.SubscriptionPlan,
+		&i.SubscriptionPeriodEnd,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, subscription_status, subscription_plan, subscription_period_end, role FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -66,40 +98,59 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.SubscriptionStatus,
+		&i.SubscriptionPlan,
+		&i.SubscriptionPeriodEnd,
+		&i.Role,
 	)
 	return i, err
 }
 
-const makeUserNotRed = `-- name: MakeUserNotRed :one
-UPDATE users SET is_chirpy_red = false WHERE id = $1 RETURNING id, is_chirpy_red
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT id, username FROM users WHERE LOWER(username) = ANY($1::text[])
 `
 
-type MakeUserNotRedRow struct {
-	ID          uuid.UUID `json:"id"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+type GetUsersByUsernamesRow struct {
+	ID       uuid.UUID      `json:"id"`
+	Username sql.NullString `json:"username"`
 }
 
-func (q *Queries) MakeUserNotRed(ctx context.Context, id uuid.UUID) (MakeUserNotRedRow, error) {
-	row := q.db.QueryRowContext(ctx, makeUserNotRed, id)
-	var i MakeUserNotRedRow
-	err := row.Scan(&i.ID, &i.IsChirpyRed)
-	return i, err
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]GetUsersByUsernamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByUsernamesRow
+	for rows.Next() {
+		var i GetUsersByUsernamesRow
+		if err := rows.Scan(&i.ID, &i.Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const makeUserRed = `-- name: MakeUserRed :one
-UPDATE users SET is_chirpy_red = true WHERE id = $1 RETURNING id, is_chirpy_red
+const lockUser = `-- name: LockUser :exec
+SELECT id FROM users WHERE id = $1 FOR UPDATE
 `
 
-type MakeUserRedRow struct {
-	ID          uuid.UUID `json:"id"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
-}
-
-func (q *Queries) MakeUserRed(ctx context.Context, id uuid.UUID) (MakeUserRedRow, error) {
-	row := q.db.QueryRowContext(ctx, makeUserRed, id)
-	var i MakeUserRedRow
-	err := row.Scan(&i.ID, &i.IsChirpyRed)
-	return i, err
+// Serializes what a user does until the end of the transaction
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
 }
 
 const reset = `-- name: Reset :exec
@@ -111,18 +162,59 @@ func (q *Queries) Reset(ctx context.Context) error {
 	return err
 }
 
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, subscription_status, subscription_plan, subscription_period_end, role
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.SubscriptionStatus,
+		&i.SubscriptionPlan,
+		&i.SubscriptionPeriodEnd,
+		&i.Role,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
-UPDATE users SET email = $2, hashed_password = $3, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+UPDATE users SET email = $2, hashed_password = $3, username = COALESCE($4, username),
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at END, updated_at = NOW()
+WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, subscription_status, subscription_plan, subscription_period_end, role
 `
 
 type UpdateUserParams struct {
-	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
+	ID             uuid.UUID      `json:"id"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	Username       sql.NullString `json:"username"`
 }
 
+// A new address has to be verified again
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.ID,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -131,6 +223,29 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.SubscriptionStatus,
+		&i.SubscriptionPlan,
+		&i.SubscriptionPeriodEnd,
+		&i.Role,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET hashed_password = $2, updated_at = NOW() WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID `json:"id"`
+	HashedPassword string    `json:"hashed_password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
@@ -0,0 +1,88 @@
+// Package entitlements decides what users can do depending on their membership tier.
+//
+// Handlers ask for the Entitlements of a user's tier instead of using constants,
+// so what Chirpy Red gives is decided in one place.
+package entitlements
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tier is a membership level
+type Tier string
+
+const (
+	Free Tier = "free"
+	// Red is for Chirpy Red members
+	Red Tier = "red"
+)
+
+// TierOf is the tier of a user
+func TierOf(isChirpyRed bool) Tier {
+	if isChirpyRed {
+		return Red
+	}
+	return Free
+}
+
+// Entitlements are the limits and features of a tier
+type Entitlements struct {
+	// MaxChirpLength is the longest chirp body, in bytes
+	MaxChirpLength int
+	// EditWindow is how long after posting a chirp can be edited
+	EditWindow time.Duration
+	// ChirpsPerHour limits posting, zero means no limit
+	ChirpsPerHour int
+}
+
+// Allows reports whether e allows at least as much as other
+func (e Entitlements) Allows(other Entitlements) bool {
+	return e.MaxChirpLength >= other.MaxChirpLength &&
+		e.EditWindow >= other.EditWindow &&
+		(e.ChirpsPerHour == 0 || (other.ChirpsPerHour != 0 && e.ChirpsPerHour >= other.ChirpsPerHour))
+}
+
+// Policy assigns entitlements to every tier
+type Policy struct {
+	Free Entitlements
+	Red  Entitlements
+}
+
+// DefaultPolicy is what chirpy gives when not configured otherwise
+func DefaultPolicy() Policy {
+	return Policy{
+		Free: Entitlements{
+			MaxChirpLength: 140,
+			EditWindow:     15 * time.Minute,
+			ChirpsPerHour:  30,
+		},
+		Red: Entitlements{
+			MaxChirpLength: 500,
+			EditWindow:     time.Hour,
+			ChirpsPerHour:  120,
+		},
+	}
+}
+
+// For returns the entitlements of a tier. Unknown tiers get the free ones.
+func (p Policy) For(t Tier) Entitlements {
+	if t == Red {
+		return p.Red
+	}
+	return p.Free
+}
+
+// Validate checks the policy makes sense: limits aren't negative, and Red never gives less than free
+func (p Policy) Validate() error {
+	for _, tier := range []Tier{Free, Red} {
+		e := p.For(tier)
+		if e.MaxChirpLength <= 0 || e.EditWindow < 0 || e.ChirpsPerHour < 0 {
+			return fmt.Errorf("invalid entitlements for tier %s: %+v", tier, e)
+		}
+	}
+	if !p.Red.Allows(p.Free) {
+		return fmt.Errorf("tier %s allows less than tier %s", Red, Free)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package entitlements
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if p.For(Free).MaxChirpLength != 140 {
+		t.Errorf("free chirps should stay at 140")
+	}
+	if p.For(TierOf(true)).MaxChirpLength <= p.For(TierOf(false)).MaxChirpLength {
+		t.Errorf("red chirps should be longer")
+	}
+	if p.For(Tier("gold")) != p.Free {
+		t.Errorf("unknown tiers should get free entitlements")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	base := Entitlements{MaxChirpLength: 140, EditWindow: time.Minute, ChirpsPerHour: 10}
+	cases := []struct {
+		name   string
+		change func(*Entitlements)
+		want   bool
+	}{
+		{"same", func(e *Entitlements) {}, true},
+		{"longer chirps", func(e *Entitlements) { e.MaxChirpLength = 280 }, true},
+		{"shorter chirps", func(e *Entitlements) { e.MaxChirpLength = 100 }, false},
+		{"shorter edit window", func(e *Entitlements) { e.EditWindow = 0 }, false},
+		{"no rate limit", func(e *Entitlements) { e.ChirpsPerHour = 0 }, true},
+		{"lower rate limit", func(e *Entitlements) { e.ChirpsPerHour = 5 }, false},
+	}
+	for _, c := range cases {
+		e := base
+		c.change(&e)
+		if got := e.Allows(base); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	unlimited := base
+	unlimited.ChirpsPerHour = 0
+	if base.Allows(unlimited) {
+		t.Errorf("a rate limit doesn't allow as much as no limit")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p := DefaultPolicy()
+	p.Red.EditWindow = time.Minute
+	if err := p.Validate(); err == nil {
+		t.Errorf("red with a shorter edit window should be refused")
+	}
+
+	p = DefaultPolicy()
+	p.Free.MaxChirpLength = 0
+	if err := p.Validate(); err == nil {
+		t.Errorf("a zero chirp length should be refused")
+	}
+}
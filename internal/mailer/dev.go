@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// LogMailer prints messages to a logger instead of sending them, for development
+type LogMailer struct {
+	// Logger defaults to the standard logger
+	Logger *log.Logger
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("MAIL to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to its own .eml file in a directory,
+// where tests and developers can read them
+type FileMailer struct {
+	Dir  string
+	From string
+
+	count atomic.Int64
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	dat, err := format(m.From, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405.000000000"), m.count.Add(1))
+	return os.WriteFile(filepath.Join(m.Dir, name), dat, 0o644)
+}
@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMessage = Message{
+	To:      "walt@example.com",
+	Subject: "Reset your password",
+	Body:    "Your token is abc.\nIt expires in an hour.",
+}
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dat, err := format("chirpy@example.com", testMessage, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(dat)
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: walt@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"Date: Wed, 01 May 2024 12:00:00 +0000\r\n",
+		"\r\n\r\nYour token is abc.\r\nIt expires in an hour.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message is missing %q:\n%s", want, got)
+		}
+	}
+
+	injected := testMessage
+	injected.To = "walt@example.com\r\nBcc: everyone@example.com"
+	if _, err := format("chirpy@example.com", injected, date); err == nil {
+		t.Errorf("expected an error for a header with a line break")
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := &LogMailer{Logger: log.New(&buf, "", 0)}
+	if err := m.Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "to=walt@example.com") || !strings.Contains(buf.String(), "Your token is abc.") {
+		t.Errorf("unexpected log output %q", buf.String())
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: filepath.Join(dir, "mail"), From: "chirpy@example.com"}
+	for range 2 {
+		if err := m.Send(context.Background(), testMessage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "mail", "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(files))
+	}
+	dat, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dat), "Subject: Reset your password") {
+		t.Errorf("unexpected message:\n%s", dat)
+	}
+}
+
+// fakeSMTPServer accepts a single message and sends what it received on the returned channel
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var transcript strings.Builder
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			transcript.WriteString(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	m := &SMTPMailer{Addr: addr, From: "chirpy@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Send(ctx, testMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	transcript := <-received
+	for _, want := range []string{
+		"MAIL FROM:<chirpy@example.com>",
+		"RCPT TO:<walt@example.com>",
+		"Subject: Reset your password",
+		"Your token is abc.",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("transcript is missing %q:\n%s", want, transcript)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package moderation checks chirp bodies against lists of moderated words.
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Grawlix replaces masked words
+const Grawlix = "****"
+
+// Action is what happens to a text containing a moderated word.
+// Actions are ordered by severity, a text gets the most severe action of its matches.
+type Action int
+
+const (
+	// Allow leaves the word alone. It's used to exempt a word from a broader list.
+	Allow Action = iota
+	// Mask replaces the word with a grawlix
+	Mask
+	// Flag accepts the text as is, but queues it for review
+	Flag
+	// Reject refuses the whole text
+	Reject
+)
+
+var actionNames = []string{"allow", "mask", "flag", "reject"}
+
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+	return actionNames[a]
+}
+
+// ParseAction reads an action by its name
+func ParseAction(s string) (Action, error) {
+	for i, name := range actionNames {
+		if strings.EqualFold(s, name) {
+			return Action(i), nil
+		}
+	}
+	return Allow, fmt.Errorf("unknown moderation action %q", s)
+}
+
+// Rule assigns an action to a word
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+// Match is a moderated word found in a text.
+// Start and End are byte offsets into the original text, End is exclusive.
+type Match struct {
+	Word   string
+	Action Action
+	Start  int
+	End    int
+}
+
+// Result is the verdict on a text
+type Result struct {
+	// Text is the original text with masked words replaced
+	Text string
+	// Action is the most severe action of all matches, Allow if there are none
+	Action Action
+	// Matches lists the moderated words found, in order of appearance.
+	// Allowed words aren't included.
+	Matches []Match
+}
+
+// Moderator decides what to do with a text
+type Moderator interface {
+	Moderate(text string) Result
+}
+
+// WordList is a Moderator matching whole words against a list of rules.
+// Words are compared after normalization, so "Kérfüffle" and "k3rfuffl3" match the rule for "kerfuffle".
+// It's safe for concurrent use, and its rules can be replaced while it's in use.
+type WordList struct {
+	mu    sync.RWMutex
+	rules map[string]Action
+}
+
+// NewWordList creates a WordList with the given rules.
+// When several rules are for the same word, the last one wins.
+func NewWordList(rules []Rule) *WordList {
+	l := &WordList{}
+	l.Replace(rules)
+	return l
+}
+
+// Replace swaps all the rules of the list
+func (l *WordList) Replace(rules []Rule) {
+	m := make(map[string]Action, len(rules))
+	for _, rule := range rules {
+		word := Normalize(rule.Word)
+		if word == "" {
+			continue
+		}
+		m[word] = rule.Action
+	}
+	l.mu.Lock()
+	l.rules = m
+	l.mu.Unlock()
+}
+
+// Moderate finds the moderated words of a text and masks them.
+// Everything that isn't part of a masked word, whitespace and punctuation included, is kept as is.
+func (l *WordList) Moderate(text string) Result {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	res := Result{Action: Allow}
+	var b strings.Builder
+	last := 0
+	for _, tok := range tokenize(text) {
+		m, ok := l.match(text, tok)
+		if !ok || m.Action == Allow {
+			continue
+		}
+		res.Matches = append(res.Matches, m)
+		res.Action = max(res.Action, m.Action)
+		if m.Action == Mask {
+			b.WriteString(text[last:m.Start])
+			b.WriteString(Grawlix)
+			last = m.End
+		}
+	}
+	b.WriteString(text[last:])
+	res.Text = b.String()
+	return res
+}
+
+// match looks a token up in the rules. Symbols standing in for letters are part of a token,
+// but at its edges they may as well be punctuation: "kerfuffle!" should match "kerfuffle",
+// "$harbert" should match "sharbert". The whole token is tried first, then its letters and digits.
+func (l *WordList) match(text string, tok span) (Match, bool) {
+	candidates := []span{tok, trimSymbols(text, tok)}
+	for _, c := range candidates {
+		word := Normalize(text[c.start:c.end])
+		if action, ok := l.rules[word]; ok {
+			return Match{Word: word, Action: action, Start: c.start, End: c.end}, true
+		}
+	}
+	return Match{}, false
+}
+
+type span struct {
+	start, end int
+}
+
+// tokenize splits a text into candidate words: runs of letters, digits and leetspeak symbols
+func tokenize(text string) []span {
+	spans := []span{}
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+func isWordRune(r rune) bool {
+	r = foldWidth(r)
+	_, leet := leetspeak[r]
+	return leet || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// trimSymbols shrinks a token to start and end with a letter or digit
+func trimSymbols(text string, tok span) span {
+	isSymbol := func(r rune) bool {
+		r = foldWidth(r)
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+	for tok.start < tok.end {
+		r, size := utf8.DecodeRuneInString(text[tok.start:tok.end])
+		if !isSymbol(r) {
+			break
+		}
+		tok.start += size
+	}
+	for tok.end > tok.start {
+		r, size := utf8.DecodeLastRuneInString(text[tok.start:tok.end])
+		if !isSymbol(r) {
+			break
+		}
+		tok.end -= size
+	}
+	return tok
+}
@@ -0,0 +1,120 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"Kerfuffle": "kerfuffle",
+		"k3rfuffl3": "kerfuffle",
+		"$h@rb3rt":  "sharbert",
+		"f0rn4x":    "fornax",
+		"Kérfüffle": "kerfuffle",
+		"ｆｏｒｎａｘ":    "fornax",
+		"Fórnax":   "fornax",
+		"zażółć":    "zazolc",
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestModerateMasks(t *testing.T) {
+	l := NewWordList(DefaultRules)
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"This is a kerfuffle opinion I need to share with the world", "This is a **** opinion I need to share with the world"},
+		{"Kerfuffle!", "****!"},
+		{"what a  kerfuffle,\tsharbert...", "what a  ****,\t****..."},
+		{"(fornax)", "(****)"},
+		{"#Fornax is a tag", "#**** is a tag"},
+		{"@sharbert hi", "@**** hi"},
+		{"k3rfuffl3 and $harbert", "**** and ****"},
+		{"ｆｏｒｎａｘ", "****"},
+		{"Kérfüffle", "****"},
+		{"kerfuffles and fornaxian are other words", "kerfuffles and fornaxian are other words"},
+		{"nothing to see here", "nothing to see here"},
+	}
+	for _, c := range cases {
+		res := l.Moderate(c.text)
+		if res.Text != c.want {
+			t.Errorf("Moderate(%q).Text = %q, want %q", c.text, res.Text, c.want)
+		}
+		wantAction := Mask
+		if c.text == c.want {
+			wantAction = Allow
+		}
+		if res.Action != wantAction {
+			t.Errorf("Moderate(%q).Action = %s, want %s", c.text, res.Action, wantAction)
+		}
+	}
+}
+
+func TestModerateActions(t *testing.T) {
+	l := NewWordList([]Rule{
+		{Word: "kerfuffle", Action: Mask},
+		{Word: "fornax", Action: Flag},
+		{Word: "sharbert", Action: Reject},
+		{Word: "fine", Action: Allow},
+	})
+
+	res := l.Moderate("a fornax and a kerfuffle")
+	if res.Action != Flag {
+		t.Errorf("expected flag, got %s", res.Action)
+	}
+	if res.Text != "a fornax and a ****" {
+		t.Errorf("flagged words should be kept, masked ones replaced, got %q", res.Text)
+	}
+	if len(res.Matches) != 2 || res.Matches[0].Word != "fornax" || res.Matches[0].Start != 2 || res.Matches[0].End != 8 {
+		t.Errorf("unexpected matches %+v", res.Matches)
+	}
+
+	if res := l.Moderate("fornax, SHARBERT"); res.Action != Reject {
+		t.Errorf("expected reject, got %s", res.Action)
+	}
+	if res := l.Moderate("this is fine"); res.Action != Allow || len(res.Matches) != 0 {
+		t.Errorf("allowed words shouldn't match, got %+v", res)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	l := NewWordList(DefaultRules)
+	l.Replace(append(DefaultRules, Rule{Word: "kerfuffle", Action: Allow}))
+	if res := l.Moderate("kerfuffle fornax"); res.Text != "kerfuffle ****" {
+		t.Errorf("later rules should override earlier ones, got %q", res.Text)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	input := `# a comment
+kerfuffle
+
+Fornax   reject
+sharbert FLAG
+`
+	rules, err := ParseRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []Rule{{"kerfuffle", Mask}, {"Fornax", Reject}, {"sharbert", Flag}}
+	if len(rules) != len(want) {
+		t.Fatalf("expected %d rules, got %+v", len(want), rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d: expected %+v, got %+v", i, want[i], rules[i])
+		}
+	}
+
+	for _, bad := range []string{"word delete", "too many fields"} {
+		if _, err := ParseRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// leetspeak maps symbols and digits commonly typed instead of letters
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'l',
+	'+': 't',
+}
+
+// diacritics maps accented latin letters to their base letter
+var diacritics = map[rune]rune{}
+
+func init() {
+	for base, accented := range map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'd': "ďđ",
+		'e': "èéêëēĕėęě",
+		'g': "ĝğġģ",
+		'h': "ĥħ",
+		'i': "ìíîïĩīĭįı",
+		'j': "ĵ",
+		'k': "ķ",
+		'l': "ĺļľŀł",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő",
+		'r': "ŕŗř",
+		's': "śŝşšș",
+		't': "ţťŧț",
+		'u': "ùúûüũūŭůűų",
+		'w': "ŵ",
+		'y': "ýÿŷ",
+		'z': "źżž",
+	} {
+		for _, r := range accented {
+			diacritics[r] = base
+		}
+	}
+}
+
+// foldWidth maps fullwidth forms, like ｆｏｒｎａｘ, to their ASCII equivalents
+func foldWidth(r rune) rune {
+	if r >= '！' && r <= '～' {
+		return r - '！' + '!'
+	}
+	return r
+}
+
+// Normalize reduces a word to the form it's compared in: lowercase, without accents,
+// with fullwidth characters and leetspeak turned into plain letters.
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		// Combining marks are what's left of decomposed accents
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(foldWidth(r))
+		if base, ok := diacritics[r]; ok {
+			r = base
+		} else if letter, ok := leetspeak[r]; ok {
+			r = letter
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
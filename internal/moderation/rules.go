@@ -0,0 +1,62 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultRules are used when no word list is configured
+var DefaultRules = []Rule{
+	{Word: "kerfuffle", Action: Mask},
+	{Word: "sharbert", Action: Mask},
+	{Word: "fornax", Action: Mask},
+}
+
+// ParseRules reads a word list. Each line holds a word, optionally followed by an action.
+// Words without an action are masked. Blank lines and lines starting with # are ignored.
+//
+//	# words to keep out of chirps
+//	kerfuffle
+//	fornax reject
+func ParseRules(r io.Reader) ([]Rule, error) {
+	rules := []Rule{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := Rule{Word: fields[0], Action: Mask}
+		switch len(fields) {
+		case 1:
+		case 2:
+			action, err := ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			rule.Action = action
+		default:
+			return nil, fmt.Errorf("line %d: expected a word and an optional action", line)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadFile reads a word list from a file, see ParseRules for the format
+func LoadFile(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRules(f)
+}
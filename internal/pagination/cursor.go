@@ -0,0 +1,133 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor marks a position in a list ordered by (created_at, id).
+// Clients only ever see it in its encoded, opaque form.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Params holds the pagination options of a single list request.
+// After and Before are exclusive bounds; when a bound is not given
+// it is set to a sentinel lying outside of any real data.
+type Params struct {
+	Limit  int32
+	After  Cursor
+	Before Cursor
+}
+
+// Lowest returns a cursor positioned before any stored row
+func Lowest() Cursor {
+	return Cursor{
+		CreatedAt: time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
+		ID:        uuid.Nil,
+	}
+}
+
+// Highest returns a cursor positioned after any stored row
+func Highest() Cursor {
+	return Cursor{
+		CreatedAt: time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC),
+		ID:        uuid.Max,
+	}
+}
+
+// Encode turns the cursor into an opaque, url-safe string.
+// Postgres stores timestamps with microsecond precision, so that's what we keep.
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%s", c.CreatedAt.UnixMicro(), c.ID.String())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func Decode(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor: %w", err)
+	}
+	micros, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return Cursor{}, fmt.Errorf("malformed cursor")
+	}
+	us, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor timestamp: %w", err)
+	}
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor id: %w", err)
+	}
+	return Cursor{
+		CreatedAt: time.UnixMicro(us).UTC(),
+		ID:        uid,
+	}, nil
+}
+
+// ParseLimit reads the page size, falling back to the default when it's not given
+func ParseLimit(s string) (int32, error) {
+	if s == "" {
+		return DefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("limit is not a number: %w", err)
+	}
+	if limit < 1 || limit > MaxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+	return int32(limit), nil
+}
+
+// ParseQuery reads the limit, after and before parameters of a request
+func ParseQuery(query url.Values) (Params, error) {
+	limit, err := ParseLimit(query.Get("limit"))
+	if err != nil {
+		return Params{}, err
+	}
+	params := Params{
+		Limit:  limit,
+		After:  Lowest(),
+		Before: Highest(),
+	}
+	if s := query.Get("after"); s != "" {
+		params.After, err = Decode(s)
+		if err != nil {
+			return Params{}, err
+		}
+	}
+	if s := query.Get("before"); s != "" {
+		params.Before, err = Decode(s)
+		if err != nil {
+			return Params{}, err
+		}
+	}
+	return params, nil
+}
+
+// Trim cuts a result fetched with limit+1 rows down to the page size.
+// If there was a surplus row, there's another page, and the returned cursor points to it.
+func Trim[T any](items []T, limit int32, cursorOf func(T) Cursor) ([]T, string) {
+	if items == nil {
+		items = []T{}
+	}
+	if len(items) <= int(limit) {
+		return items, ""
+	}
+	items = items[:limit]
+	return items, cursorOf(items[len(items)-1]).Encode()
+}
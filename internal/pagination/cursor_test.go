@@ -0,0 +1,79 @@
+package pagination
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{
+		CreatedAt: time.Date(2024, time.March, 3, 12, 30, 15, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+	decoded, err := Decode(c.Encode())
+	if err != nil {
+		t.Fatalf("error decoding cursor: %s", err)
+	}
+	if !decoded.CreatedAt.Equal(c.CreatedAt) || decoded.ID != c.ID {
+		t.Errorf("cursor changed in round trip\nOriginal: %v\nDecoded: %v", c, decoded)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, s := range []string{"", "!!!", "bm90LWEtY3Vyc29y", "MTIzOm5vdC1hLXV1aWQ"} {
+		if _, err := Decode(s); err == nil {
+			t.Errorf("expected an error decoding %q", s)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	if limit, err := ParseLimit(""); err != nil || limit != DefaultLimit {
+		t.Errorf("expected default limit, got %d, %v", limit, err)
+	}
+	if limit, err := ParseLimit("5"); err != nil || limit != 5 {
+		t.Errorf("expected limit 5, got %d, %v", limit, err)
+	}
+	for _, s := range []string{"0", "-1", "abc", "101"} {
+		if _, err := ParseLimit(s); err == nil {
+			t.Errorf("expected an error parsing limit %q", s)
+		}
+	}
+}
+
+func TestParseQueryDefaults(t *testing.T) {
+	params, err := ParseQuery(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.After != Lowest() || params.Before != Highest() {
+		t.Errorf("expected sentinel bounds, got %v and %v", params.After, params.Before)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	cursorOf := func(id uuid.UUID) Cursor { return Cursor{ID: id} }
+
+	page, next := Trim(ids, 2, cursorOf)
+	if len(page) != 2 {
+		t.Errorf("expected 2 items, got %d", len(page))
+	}
+	c, err := Decode(next)
+	if err != nil || c.ID != ids[1] {
+		t.Errorf("next cursor should point at the last returned item")
+	}
+
+	page, next = Trim(ids, 3, cursorOf)
+	if len(page) != 3 || next != "" {
+		t.Errorf("expected a final page without a cursor")
+	}
+
+	page, _ = Trim[uuid.UUID](nil, 3, cursorOf)
+	if page == nil {
+		t.Errorf("expected an empty, non-nil page")
+	}
+}
@@ -0,0 +1,94 @@
+// Package search turns what users type in a search box into PostgreSQL text search queries.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrNoTerms is returned for queries without a single word to look for
+var ErrNoTerms = errors.New("search query has no words to look for")
+
+// term is one element of a query: a word or a phrase, possibly negated
+type term struct {
+	words   []string
+	prefix  bool
+	negated bool
+}
+
+func (t term) String() string {
+	s := strings.Join(t.words, " <-> ")
+	if t.prefix {
+		s += ":*"
+	}
+	if len(t.words) > 1 {
+		s = "(" + s + ")"
+	}
+	if t.negated {
+		s = "!" + s
+	}
+	return s
+}
+
+// BuildTSQuery converts a user's query into the syntax of to_tsquery.
+// All terms must match. "Quoted words" must appear next to each other in that order,
+// a trailing * matches words starting with the prefix and a leading - excludes a term.
+// Anything other than letters and digits only separates words,
+// so the result is always valid input for to_tsquery.
+func BuildTSQuery(query string) (string, error) {
+	terms := []term{}
+	positive := false
+	rest := query
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+		t := term{}
+		if after, ok := strings.CutPrefix(rest, "-"); ok {
+			t.negated = true
+			rest = after
+		}
+
+		// A phrase runs to the closing quote or the end of the query,
+		// a single word to the next space or quote
+		var token string
+		if after, ok := strings.CutPrefix(rest, `"`); ok {
+			token, rest, _ = strings.Cut(after, `"`)
+			if after, ok := strings.CutPrefix(rest, "*"); ok {
+				token += "*"
+				rest = after
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			token, rest = rest[:end], rest[end:]
+		}
+
+		if after, ok := strings.CutSuffix(strings.TrimSpace(token), "*"); ok {
+			t.prefix = true
+			token = after
+		}
+		t.words = strings.FieldsFunc(strings.ToLower(token), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(t.words) == 0 {
+			continue
+		}
+		positive = positive || !t.negated
+		terms = append(terms, t)
+	}
+	// A query that only excludes would match almost every chirp
+	if !positive {
+		return "", ErrNoTerms
+	}
+
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, " & "), nil
+}
@@ -0,0 +1,43 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildTSQuery(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"golang", "golang"},
+		{"Go  SQL", "go & sql"},
+		{`"hello world" again`, "(hello <-> world) & again"},
+		{"chirp*", "chirp:*"},
+		{`"new chirp*"`, "(new <-> chirp:*)"},
+		{"cats -dogs", "cats & !dogs"},
+		{`cats -"hot dogs"`, "cats & !(hot <-> dogs)"},
+		{"don't", "(don <-> t)"},
+		{"a&b | !c:* (d)", "(a <-> b) & c:* & d"},
+		{`unterminated "quote here`, "unterminated & (quote <-> here)"},
+		{"zażółć 自分", "zażółć & 自分"},
+	}
+	for _, c := range cases {
+		got, err := BuildTSQuery(c.query)
+		if err != nil {
+			t.Errorf("BuildTSQuery(%q) returned an error: %s", c.query, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("BuildTSQuery(%q) = %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestBuildTSQueryNoTerms(t *testing.T) {
+	for _, query := range []string{"", "   ", `""`, "!?*", "-dogs", `-"hot dogs"`} {
+		if _, err := BuildTSQuery(query); !errors.Is(err, ErrNoTerms) {
+			t.Errorf("BuildTSQuery(%q): expected ErrNoTerms, got %v", query, err)
+		}
+	}
+}
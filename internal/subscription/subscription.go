@@ -0,0 +1,156 @@
+// Package subscription tracks the Chirpy Red subscription of a user through the events of its billing provider.
+//
+// A subscription is a small state machine. Billing events and the passing of time move it between states,
+// and whether the user gets Chirpy Red follows from the state and the end of the paid period.
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is the state of a subscription
+type Status string
+
+const (
+	// None is a user who never subscribed
+	None Status = "none"
+	// Active is paid for until the end of the period, and renews
+	Active Status = "active"
+	// PastDue failed to renew. Chirpy Red is kept for a grace period while the payment is retried.
+	PastDue Status = "past_due"
+	// Canceled won't renew, Chirpy Red is kept until the end of the paid period
+	Canceled Status = "canceled"
+	// Expired ran out
+	Expired Status = "expired"
+	// Refunded was paid back, Chirpy Red ends right away
+	Refunded Status = "refunded"
+)
+
+// ParseStatus reads a status as stored
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case None, Active, PastDue, Canceled, Expired, Refunded:
+		return status, nil
+	}
+	return None, fmt.Errorf("unknown subscription status %q", s)
+}
+
+// Event types. The first ones come from the billing provider, Expire is sent when a period runs out.
+const (
+	Upgraded      = "user.upgraded"
+	Renewed       = "user.renewed"
+	PaymentFailed = "user.payment_failed"
+	Downgraded    = "user.downgraded"
+	RefundIssued  = "user.refunded"
+	Expire        = "subscription.expired"
+)
+
+// DefaultPeriod is the length of a period when an event doesn't give its end
+const DefaultPeriod = 30 * 24 * time.Hour
+
+// GracePeriod is how long a past due subscription keeps Chirpy Red after its period ends
+const GracePeriod = 7 * 24 * time.Hour
+
+var (
+	// ErrUnknownEvent is an event type this package doesn't know
+	ErrUnknownEvent = errors.New("unknown subscription event")
+	// ErrIgnored is an event that doesn't apply to the subscription in its current state,
+	// like a failed payment of a subscription that was already canceled
+	ErrIgnored = errors.New("subscription event doesn't apply")
+)
+
+// Subscription is the subscription of a user
+type Subscription struct {
+	Status Status
+	Plan   string
+	// PeriodEnd is the end of the paid period. Subscriptions from before periods were tracked
+	// have none, they last until they're downgraded.
+	PeriodEnd time.Time
+}
+
+// Event is something that happened to a subscription
+type Event struct {
+	Type string
+	// Plan and PeriodEnd are given by upgrades and renewals, they may be left empty
+	Plan      string
+	PeriodEnd time.Time
+}
+
+// Apply returns the subscription after an event, at the time now.
+// Events that don't apply return ErrIgnored, with the subscription unchanged.
+func Apply(s Subscription, e Event, now time.Time) (Subscription, error) {
+	switch e.Type {
+	case Upgraded, Renewed:
+		// A renewal of a subscription we never saw start still means it's paid for
+		next := Subscription{Status: Active, Plan: s.Plan, PeriodEnd: e.PeriodEnd}
+		if e.Plan != "" {
+			next.Plan = e.Plan
+		}
+		if next.PeriodEnd.IsZero() {
+			start := now
+			if e.Type == Renewed && s.PeriodEnd.After(now) {
+				start = s.PeriodEnd
+			}
+			next.PeriodEnd = start.Add(DefaultPeriod)
+		}
+		return next, nil
+
+	case PaymentFailed:
+		if s.Status != Active {
+			return s, ErrIgnored
+		}
+		s.Status = PastDue
+		return s, nil
+
+	case Downgraded:
+		if s.Status != Active && s.Status != PastDue {
+			return s, ErrIgnored
+		}
+		// Without a known period, or once it's over, there's nothing left to keep
+		if s.Status == PastDue || s.PeriodEnd.IsZero() || !s.PeriodEnd.After(now) {
+			s.Status = Expired
+		} else {
+			s.Status = Canceled
+		}
+		return s, nil
+
+	case RefundIssued:
+		if s.Status == None || s.Status == Refunded {
+			return s, ErrIgnored
+		}
+		s.Status = Refunded
+		return s, nil
+
+	case Expire:
+		until, ok := s.EntitledUntil()
+		if !ok || until.IsZero() || now.Before(until) {
+			return s, ErrIgnored
+		}
+		s.Status = Expired
+		return s, nil
+	}
+	return s, fmt.Errorf("%w %q", ErrUnknownEvent, e.Type)
+}
+
+// EntitledUntil is when Chirpy Red ends for the subscription, if it has it at all.
+// The zero time means it doesn't end by itself.
+func (s Subscription) EntitledUntil() (time.Time, bool) {
+	switch s.Status {
+	case Active, Canceled:
+		return s.PeriodEnd, true
+	case PastDue:
+		if s.PeriodEnd.IsZero() {
+			return time.Time{}, true
+		}
+		return s.PeriodEnd.Add(GracePeriod), true
+	}
+	return time.Time{}, false
+}
+
+// Entitled reports whether the subscription gives Chirpy Red at the time now
+func (s Subscription) Entitled(now time.Time) bool {
+	until, ok := s.EntitledUntil()
+	return ok && (until.IsZero() || now.Before(until))
+}
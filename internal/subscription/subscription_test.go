@@ -0,0 +1,129 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestLifecycle(t *testing.T) {
+	s := Subscription{Status: None}
+	if s.Entitled(now) {
+		t.Fatalf("nobody is entitled before subscribing")
+	}
+
+	steps := []struct {
+		event    Event
+		at       time.Time
+		status   Status
+		entitled bool
+	}{
+		{Event{Type: Upgraded, Plan: "monthly"}, now, Active, true},
+		{Event{Type: Renewed}, now.Add(29 * 24 * time.Hour), Active, true},
+		{Event{Type: PaymentFailed}, now.Add(60 * 24 * time.Hour), PastDue, true},
+		{Event{Type: Renewed, PeriodEnd: now.Add(100 * 24 * time.Hour)}, now.Add(61 * 24 * time.Hour), Active, true},
+		{Event{Type: Downgraded}, now.Add(70 * 24 * time.Hour), Canceled, true},
+		{Event{Type: Expire}, now.Add(100 * 24 * time.Hour), Expired, false},
+		{Event{Type: Upgraded, Plan: "yearly", PeriodEnd: now.Add(500 * 24 * time.Hour)}, now.Add(120 * 24 * time.Hour), Active, true},
+		{Event{Type: RefundIssued}, now.Add(121 * 24 * time.Hour), Refunded, false},
+	}
+	for i, step := range steps {
+		next, err := Apply(s, step.event, step.at)
+		if err != nil {
+			t.Fatalf("step %d (%s): %s", i, step.event.Type, err)
+		}
+		if next.Status != step.status {
+			t.Errorf("step %d (%s): status %s, want %s", i, step.event.Type, next.Status, step.status)
+		}
+		if next.Entitled(step.at) != step.entitled {
+			t.Errorf("step %d (%s): entitled %v, want %v", i, step.event.Type, next.Entitled(step.at), step.entitled)
+		}
+		s = next
+	}
+	if s.Plan != "yearly" {
+		t.Errorf("plan %q, want yearly", s.Plan)
+	}
+}
+
+func TestRenewalExtendsPeriod(t *testing.T) {
+	s := Subscription{Status: Active, PeriodEnd: now.Add(24 * time.Hour)}
+	next, err := Apply(s, Event{Type: Renewed}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(24*time.Hour + DefaultPeriod); !next.PeriodEnd.Equal(want) {
+		t.Errorf("period ends %s, want %s", next.PeriodEnd, want)
+	}
+}
+
+func TestPastDueGracePeriod(t *testing.T) {
+	s := Subscription{Status: PastDue, PeriodEnd: now}
+	if !s.Entitled(now.Add(GracePeriod - time.Minute)) {
+		t.Errorf("past due subscription should be entitled during the grace period")
+	}
+	if _, err := Apply(s, Event{Type: Expire}, now.Add(time.Hour)); !errors.Is(err, ErrIgnored) {
+		t.Errorf("expired during the grace period: %v", err)
+	}
+	next, err := Apply(s, Event{Type: Expire}, now.Add(GracePeriod))
+	if err != nil || next.Status != Expired {
+		t.Errorf("got %s, %v", next.Status, err)
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	// Subscriptions from before periods were tracked have nothing to keep
+	legacy := Subscription{Status: Active}
+	next, err := Apply(legacy, Event{Type: Downgraded}, now)
+	if err != nil || next.Status != Expired || next.Entitled(now) {
+		t.Errorf("got %+v, %v", next, err)
+	}
+	if !legacy.Entitled(now.Add(1000 * DefaultPeriod)) {
+		t.Errorf("legacy subscriptions don't expire by themselves")
+	}
+
+	lapsed := Subscription{Status: Active, PeriodEnd: now.Add(-time.Hour)}
+	if next, _ := Apply(lapsed, Event{Type: Downgraded}, now); next.Status != Expired {
+		t.Errorf("got %s, want expired", next.Status)
+	}
+}
+
+func TestIgnoredEvents(t *testing.T) {
+	cases := []struct {
+		status Status
+		event  string
+	}{
+		{None, PaymentFailed},
+		{None, Downgraded},
+		{None, RefundIssued},
+		{Canceled, PaymentFailed},
+		{Expired, Downgraded},
+		{Refunded, RefundIssued},
+		{Active, Expire},
+		{Expired, Expire},
+	}
+	for _, c := range cases {
+		s := Subscription{Status: c.status, PeriodEnd: now.Add(time.Hour)}
+		next, err := Apply(s, Event{Type: c.event}, now)
+		if !errors.Is(err, ErrIgnored) {
+			t.Errorf("%s on %s: got %v, want ErrIgnored", c.event, c.status, err)
+		}
+		if next != s {
+			t.Errorf("%s on %s changed the subscription", c.event, c.status)
+		}
+	}
+
+	if _, err := Apply(Subscription{Status: Active}, Event{Type: "user.teleported"}, now); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("got %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	if s, err := ParseStatus("past_due"); err != nil || s != PastDue {
+		t.Errorf("got %s, %v", s, err)
+	}
+	if _, err := ParseStatus("gold"); err == nil {
+		t.Errorf("expected an error for an unknown status")
+	}
+}
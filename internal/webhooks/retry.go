@@ -0,0 +1,27 @@
+package webhooks
+
+import "time"
+
+// RetryPolicy decides when failed deliveries are tried again.
+// The wait doubles after every failed attempt, up to Max. After MaxAttempts the delivery is dead.
+type RetryPolicy struct {
+	Base        time.Duration
+	Max         time.Duration
+	MaxAttempts int
+}
+
+// DefaultRetryPolicy retries for about a day: after 30 seconds, a minute, 2 minutes... up to 6 hours apart
+var DefaultRetryPolicy = RetryPolicy{Base: 30 * time.Second, Max: 6 * time.Hour, MaxAttempts: 12}
+
+// Next is how long to wait after the given number of failed attempts.
+// It returns false once the delivery should be given up on.
+func (p RetryPolicy) Next(attempts int) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+	wait := p.Base
+	for i := 1; i < attempts && wait < p.Max; i++ {
+		wait *= 2
+	}
+	return min(wait, p.Max), true
+}
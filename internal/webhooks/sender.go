@@ -0,0 +1,117 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// Headers of a delivery
+const (
+	HeaderEvent     = "X-Chirpy-Event"
+	HeaderDelivery  = "X-Chirpy-Delivery"
+	HeaderTimestamp = "X-Chirpy-Timestamp"
+	HeaderSignature = "X-Chirpy-Signature"
+)
+
+// ErrPrivateAddress is returned for endpoints that resolve to an address of our own network
+var ErrPrivateAddress = errors.New("webhook endpoint resolves to a private address")
+
+// Delivery is one event sent to one endpoint
+type Delivery struct {
+	ID      string
+	URL     string
+	Secret  []byte
+	Event   string
+	Payload []byte
+}
+
+// Result is the outcome of an attempt to send a delivery.
+// StatusCode is zero if no response was received.
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+// OK reports whether the endpoint accepted the delivery
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// Sender sends signed deliveries over HTTP
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a sender giving up on endpoints after timeout.
+// Endpoints on loopback, private and link-local addresses are refused unless allowPrivate is set,
+// so users can't point webhooks at our own network.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// A redirect could lead anywhere, endpoints have to answer themselves
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Send makes one attempt at a delivery. Any 2xx response is a success.
+func (s *Sender) Send(ctx context.Context, d Delivery) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+	now := s.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, Timestamp(now))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, now, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+	// Reading a little of the body lets the connection be reused, we don't need the rest
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Result{StatusCode: resp.StatusCode, Err: fmt.Errorf("endpoint responded %s", resp.Status)}
+	}
+	return Result{StatusCode: resp.StatusCode}
+}
+
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
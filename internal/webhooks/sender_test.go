@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	secret := []byte("whsec_test")
+	payload := []byte(`{"type":"chirp.created"}`)
+
+	var received http.Header
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = r.Header.Clone()
+		verifyErr = Verify(secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Now(), time.Minute)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s := NewSender(5*time.Second, true)
+	res := s.Send(context.Background(), Delivery{ID: "d1", URL: srv.URL, Secret: secret, Event: "chirp.created", Payload: payload})
+	if !res.OK() || res.StatusCode != http.StatusAccepted {
+		t.Fatalf("got %+v", res)
+	}
+	if verifyErr != nil {
+		t.Errorf("receiver couldn't verify the signature: %s", verifyErr)
+	}
+	if received.Get(HeaderEvent) != "chirp.created" || received.Get(HeaderDelivery) != "d1" {
+		t.Errorf("unexpected headers %v", received)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/redirect":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSender(100*time.Millisecond, true)
+	cases := []struct {
+		path string
+		code int
+	}{
+		{"/error", http.StatusInternalServerError},
+		{"/redirect", http.StatusFound},
+		{"/slow", 0},
+	}
+	for _, c := range cases {
+		res := s.Send(context.Background(), Delivery{URL: srv.URL + c.path, Payload: []byte("{}")})
+		if res.OK() {
+			t.Errorf("%s: expected a failure", c.path)
+		}
+		if res.StatusCode != c.code {
+			t.Errorf("%s: status %d, want %d", c.path, res.StatusCode, c.code)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request reached a private address")
+	}))
+	defer srv.Close()
+
+	s := NewSender(time.Second, false)
+	res := s.Send(context.Background(), Delivery{URL: srv.URL, Payload: []byte("{}")})
+	if !errors.Is(res.Err, ErrPrivateAddress) {
+		t.Errorf("got %v, want ErrPrivateAddress", res.Err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{Base: time.Second, Max: 10 * time.Second, MaxAttempts: 6}
+	want := []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}
+	for attempts, w := range want {
+		got, ok := p.Next(attempts)
+		if !ok || got != w {
+			t.Errorf("Next(%d) = %s, %v, want %s", attempts, got, ok, w)
+		}
+	}
+	if _, ok := p.Next(6); ok {
+		t.Errorf("delivery should be dead after 6 attempts")
+	}
+}
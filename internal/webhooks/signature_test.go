@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	sent := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ts := Timestamp(sent)
+	sig := Sign(secret, sent, body)
+
+	cases := []struct {
+		name      string
+		secret    []byte
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		want      error
+	}{
+		{"valid", secret, ts, sig, body, sent.Add(time.Minute), nil},
+		{"rotated secrets", secret, ts, Sign([]byte("old"), sent, body) + ", " + sig, body, sent, nil},
+		{"wrong secret", []byte("other"), ts, sig, body, sent, ErrInvalidSignature},
+		{"empty secret", nil, ts, Sign(nil, sent, body), body, sent, ErrInvalidSignature},
+		{"tampered body", secret, ts, sig, []byte(`{"event":"user.upgraded"}`), sent, ErrInvalidSignature},
+		{"tampered timestamp", secret, Timestamp(sent.Add(time.Second)), sig, body, sent, ErrInvalidSignature},
+		{"unknown version", secret, ts, "v0=" + sig[3:], body, sent, ErrInvalidSignature},
+		{"garbage signature", secret, ts, "v1=zz", body, sent, ErrInvalidSignature},
+		{"missing signature", secret, ts, "", body, sent, ErrMissingSignature},
+		{"missing timestamp", secret, "", sig, body, sent, ErrMissingSignature},
+		{"invalid timestamp", secret, "yesterday", sig, body, sent, ErrInvalidTimestamp},
+		{"replayed later", secret, ts, sig, body, sent.Add(10 * time.Minute), ErrStaleTimestamp},
+		{"from the future", secret, ts, sig, body, sent.Add(-10 * time.Minute), ErrStaleTimestamp},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Verify(c.secret, c.timestamp, c.signature, c.body, c.now, 5*time.Minute)
+			if !errors.Is(err, c.want) {
+				t.Errorf("got %v, want %v", err, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type likeResponse struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	LikeCount int32     `json:"like_count"`
+	LikedByMe bool      `json:"liked_by_me"`
+}
+
+func (cfg *apiConfig) handleLikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.changeLike(w, r, true)
+}
+
+func (cfg *apiConfig) handleUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.changeLike(w, r, false)
+}
+
+// changeLike adds or removes the user's like on a chirp.
+// Both are idempotent: the counter only moves when the likes table actually changes,
+// and both happen in one transaction so the counter can't drift from the likes.
+func (cfg *apiConfig) changeLike(w http.ResponseWriter, r *http.Request, like bool) {
+	inUID := requestUserID(r)
+
+	reqId, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondError(w, "Error parsing chirp id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetChirpById(r.Context(), reqId)
+	if err != nil {
+		respondError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	var changed int64
+	delta := int32(1)
+	if like {
+		changed, err = qtx.LikeChirp(r.Context(), database.LikeChirpParams{UserID: inUID, ChirpID: chirp.ID})
+	} else {
+		changed, err = qtx.UnlikeChirp(r.Context(), database.UnlikeChirpParams{UserID: inUID, ChirpID: chirp.ID})
+		delta = -1
+	}
+	if err != nil {
+		log.Printf("Error changing like in database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	likeCount := chirp.LikeCount
+	if changed > 0 {
+		likeCount, err = qtx.AddChirpLikes(r.Context(), database.AddChirpLikesParams{Delta: delta, ID: chirp.ID})
+		if err != nil {
+			log.Printf("Error updating like count: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing like: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(likeResponse{
+		ChirpID:   chirp.ID,
+		LikeCount: likeCount,
+		LikedByMe: like,
+	})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
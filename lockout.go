@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Failed logins are forgotten after a day without any
+const loginFailureMemory = 24 * time.Hour
+
+// An account gets 5 tries, then waits from 30 seconds doubling up to an hour
+var accountBackoff = auth.LoginBackoff{FreeAttempts: 5, Base: 30 * time.Second, Max: time.Hour}
+
+// Clients get more, many users can share an address
+var clientBackoff = auth.LoginBackoff{FreeAttempts: 50, Base: 30 * time.Second, Max: time.Hour}
+
+func accountLoginKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func clientLoginKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// loginLockedUntil is when logins with any of the keys are allowed again.
+// It's in the past if they're allowed now.
+func (cfg *apiConfig) loginLockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	failures, err := cfg.db.GetLoginFailures(ctx, keys)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var until time.Time
+	for _, f := range failures {
+		if f.LockedUntil.Valid && f.LockedUntil.Time.After(until) {
+			until = f.LockedUntil.Time
+		}
+	}
+	return until, nil
+}
+
+// recordLoginFailure counts a failed login against its account and client, and makes them wait if they've failed too often
+func (cfg *apiConfig) recordLoginFailure(r *http.Request, accountKey string) error {
+	now := time.Now().UTC()
+	for _, key := range []string{accountKey, clientLoginKey(r)} {
+		failure, err := cfg.db.RecordLoginFailure(r.Context(), database.RecordLoginFailureParams{
+			Key:          key,
+			FailedAt:     now,
+			ForgetBefore: now.Add(-loginFailureMemory),
+		})
+		if err != nil {
+			return err
+		}
+
+		backoff := accountBackoff
+		if key != accountKey {
+			backoff = clientBackoff
+		}
+		until := backoff.LockedUntil(int(failure.Failures), now)
+		if until.IsZero() {
+			continue
+		}
+		err = cfg.db.SetLoginLockout(r.Context(), database.SetLoginLockoutParams{
+			Key:         key,
+			LockedUntil: sql.NullTime{Time: until, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		if int(failure.Failures) == backoff.FreeAttempts+1 {
+			logSecurityEvent(r, "login_throttled", uuid.Nil, fmt.Sprintf("%s after %d failures", key, failure.Failures))
+		}
+	}
+	return nil
+}
+
+// checkLoginAllowed refuses logins while any of the keys has to wait.
+// If the login is refused, the response has been written.
+func (cfg *apiConfig) checkLoginAllowed(w http.ResponseWriter, r *http.Request, keys ...string) bool {
+	until, err := cfg.loginLockedUntil(r.Context(), keys...)
+	if err != nil {
+		log.Printf("Error checking failed logins: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return false
+	}
+	wait := until.Sub(time.Now().UTC())
+	if wait <= 0 {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	respondError(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+	return false
+}
+
+func (cfg *apiConfig) handlerGetLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	// Accounts and clients that can't log in right now, the longest locked first
+	type lockoutResponse struct {
+		Key           string     `json:"key"`
+		Failures      int32      `json:"failures"`
+		LastFailureAt time.Time  `json:"last_failure_at"`
+		LockedUntil   *time.Time `json:"locked_until"`
+	}
+
+	lockouts, err := cfg.db.ListLoginLockouts(r.Context(), time.Now().UTC())
+	if err != nil {
+		log.Printf("Error getting lockouts from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]lockoutResponse, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		item := lockoutResponse{
+			Key:           lockout.Key,
+			Failures:      lockout.Failures,
+			LastFailureAt: lockout.LastFailureAt,
+		}
+		if lockout.LockedUntil.Valid {
+			item.LockedUntil = &lockout.LockedUntil.Time
+		}
+		resp = append(resp, item)
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleClearLoginLockout(w http.ResponseWriter, r *http.Request) {
+	// Forgets the failed logins of a key from the lockout list, like "ip:192.0.2.1"
+	cleared, err := cfg.db.ClearLoginFailures(r.Context(), r.PathValue("key"))
+	if err != nil {
+		log.Printf("Error clearing failed logins: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if cleared == 0 {
+		respondError(w, "Lockout not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	// Lets a user log in again right away, whatever their failed logins
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := cfg.db.ClearLoginFailures(r.Context(), accountLoginKey(user.Email)); err != nil {
+		log.Printf("Error clearing failed logins: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logSecurityEvent(r, "login_unlocked", user.ID, "by admin")
+
+	w.WriteHeader(http.StatusNoContent)
+}
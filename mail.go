@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/mailer"
+)
+
+// Sending an e-mail gives up after 30 seconds
+const mailTimeout = 30 * time.Second
+
+// sendMail sends an e-mail in the background. Requests don't wait for the mail server,
+// so how long they take doesn't tell whether an e-mail was sent.
+func (cfg *apiConfig) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := cfg.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending e-mail %q to %s: %s", msg.Subject, msg.To, err)
+		}
+	}()
+}
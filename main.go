@@ -1,23 +1,41 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"sync/atomic"
+	"time"
 
+	"github.com/Denisowiec/Chirpy/internal/auth"
 	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/entitlements"
+	"github.com/Denisowiec/Chirpy/internal/mailer"
+	"github.com/Denisowiec/Chirpy/internal/moderation"
+	"github.com/Denisowiec/Chirpy/internal/webhooks"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             database.Queries
-	jwtSecretCode  string
-	polkaApiKey    string
+	fileserverHits       atomic.Int32
+	db                   database.Queries
+	dbConn               *sql.DB
+	jwtKeys              *auth.KeySet
+	polkaApiKey          string
+	platform             string
+	polkaWebhookSecret   []byte
+	entitlements         entitlements.Policy
+	moderator            *moderation.WordList
+	moderationBase       []moderation.Rule
+	mailer               mailer.Mailer
+	requireVerifiedEmail bool
+	webhookSender        *webhooks.Sender
+	webhooksAllowPrivate bool
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -38,26 +56,228 @@ func main() {
 	mux := http.NewServeMux()
 	apiCfg := apiConfig{}
 	apiCfg.db = *dbQueries
-	apiCfg.jwtSecretCode = os.Getenv("JWT_SECRET_CODE")
+	apiCfg.dbConn = db
+
+	// chirpy bootstrap-admin creates the first admin instead of running the server
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		if err := bootstrapAdmin(context.Background(), dbQueries, os.Args[2:]); err != nil {
+			log.Fatalf("Error creating admin: %s", err)
+		}
+		return
+	}
+
+	// PLATFORM=dev enables what's unsafe in production, like logging e-mails or resetting the database
+	apiCfg.platform = os.Getenv("PLATFORM")
+
+	// Tokens are signed with the keys in JWT_KEYS_DIR if it's set, with JWT_SECRET_CODE otherwise.
+	// With both set, tokens signed with the secret are still accepted, to allow switching without logging everyone out.
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		apiCfg.jwtKeys, err = auth.LoadKeySet(dir, os.Getenv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %s", err)
+		}
+		if secret := os.Getenv("JWT_SECRET_CODE"); secret != "" {
+			apiCfg.jwtKeys.AddHMAC(secret)
+		}
+	} else {
+		apiCfg.jwtKeys = auth.NewHMACKeySet(os.Getenv("JWT_SECRET_CODE"))
+	}
+
+	// Tokens must come from chirpy, JWT_AUDIENCE, JWT_LEEWAY and JWT_MAX_AGE tighten the checks further
+	validation := auth.DefaultValidationOptions()
+	validation.Audience = os.Getenv("JWT_AUDIENCE")
+	if s := os.Getenv("JWT_LEEWAY"); s != "" {
+		validation.Leeway, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Error parsing JWT_LEEWAY: %s", err)
+		}
+	}
+	if s := os.Getenv("JWT_MAX_AGE"); s != "" {
+		validation.MaxAge, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Error parsing JWT_MAX_AGE: %s", err)
+		}
+	}
+	apiCfg.jwtKeys.SetValidationOptions(validation)
 	apiCfg.polkaApiKey = os.Getenv("POLKA_KEY")
+	// Polka deliveries have to be signed with POLKA_WEBHOOK_SECRET.
+	// Only in development can it be left out, unsigned deliveries are then accepted.
+	apiCfg.polkaWebhookSecret = []byte(os.Getenv("POLKA_WEBHOOK_SECRET"))
+	if len(apiCfg.polkaWebhookSecret) == 0 {
+		if apiCfg.platform != "dev" {
+			log.Fatalf("POLKA_WEBHOOK_SECRET is not set")
+		}
+		log.Printf("POLKA_WEBHOOK_SECRET is not set, accepting unsigned Polka webhooks")
+	}
+
+	// What each membership tier allows. CHIRP_EDIT_WINDOW sets the edit window of free users,
+	// Chirpy Red members never get a shorter one.
+	apiCfg.entitlements = entitlements.DefaultPolicy()
+	if s := os.Getenv("CHIRP_EDIT_WINDOW"); s != "" {
+		apiCfg.entitlements.Free.EditWindow, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Error parsing CHIRP_EDIT_WINDOW: %s", err)
+		}
+		apiCfg.entitlements.Red.EditWindow = max(apiCfg.entitlements.Red.EditWindow, apiCfg.entitlements.Free.EditWindow)
+	}
+	if err := apiCfg.entitlements.Validate(); err != nil {
+		log.Fatalf("Error in entitlements: %s", err)
+	}
+
+	// The moderated words come from MODERATION_WORDS_FILE, or the built-in list if it isn't set.
+	// Words set by admins in the database override them.
+	apiCfg.moderationBase = moderation.DefaultRules
+	if path := os.Getenv("MODERATION_WORDS_FILE"); path != "" {
+		apiCfg.moderationBase, err = moderation.LoadFile(path)
+		if err != nil {
+			log.Fatalf("Error loading MODERATION_WORDS_FILE: %s", err)
+		}
+	}
+	apiCfg.moderator = moderation.NewWordList(apiCfg.moderationBase)
+	if err := apiCfg.reloadModeration(context.Background()); err != nil {
+		log.Printf("Error loading moderated words from database: %s", err)
+	}
+
+	// E-mails go out over SMTP if MAIL_SMTP_ADDR is set. Otherwise they're written to MAIL_DIR,
+	// or, in development only, to the log: e-mails carry password reset tokens.
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "chirpy@localhost"
+	}
+	if addr := os.Getenv("MAIL_SMTP_ADDR"); addr != "" {
+		apiCfg.mailer = &mailer.SMTPMailer{
+			Addr:     addr,
+			From:     mailFrom,
+			Username: os.Getenv("MAIL_SMTP_USER"),
+			Password: os.Getenv("MAIL_SMTP_PASSWORD"),
+		}
+	} else if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		apiCfg.mailer = &mailer.FileMailer{Dir: dir, From: mailFrom}
+	} else if apiCfg.platform == "dev" {
+		apiCfg.mailer = &mailer.LogMailer{}
+	} else {
+		log.Fatalf("No mailer configured: set MAIL_SMTP_ADDR or MAIL_DIR, or PLATFORM=dev to log e-mails")
+	}
+
+	// Posting only requires a verified e-mail address with REQUIRE_VERIFIED_EMAIL=true
+	if s := os.Getenv("REQUIRE_VERIFIED_EMAIL"); s != "" {
+		apiCfg.requireVerifiedEmail, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("Error parsing REQUIRE_VERIFIED_EMAIL: %s", err)
+		}
+	}
+
+	// Subscriptions whose period is over are expired every hour, unless SUBSCRIPTION_SWEEP_INTERVAL says otherwise
+	sweepInterval := time.Hour
+	if s := os.Getenv("SUBSCRIPTION_SWEEP_INTERVAL"); s != "" {
+		sweepInterval, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Error parsing SUBSCRIPTION_SWEEP_INTERVAL: %s", err)
+		}
+	}
+	go apiCfg.runSubscriptionSweeper(context.Background(), sweepInterval)
+
+	// Webhooks are only sent to public addresses, WEBHOOKS_ALLOW_PRIVATE lifts that for local development.
+	// Due deliveries are sent every 5 seconds, unless WEBHOOK_DISPATCH_INTERVAL says otherwise.
+	if s := os.Getenv("WEBHOOKS_ALLOW_PRIVATE"); s != "" {
+		apiCfg.webhooksAllowPrivate, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("Error parsing WEBHOOKS_ALLOW_PRIVATE: %s", err)
+		}
+	}
+	apiCfg.webhookSender = webhooks.NewSender(webhookSendTimeout, apiCfg.webhooksAllowPrivate)
+	dispatchInterval := 5 * time.Second
+	if s := os.Getenv("WEBHOOK_DISPATCH_INTERVAL"); s != "" {
+		dispatchInterval, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Error parsing WEBHOOK_DISPATCH_INTERVAL: %s", err)
+		}
+	}
+	go apiCfg.runWebhookDispatcher(context.Background(), dispatchInterval)
+
+	// Routes that need an access token declare the scopes it must grant
+	requireScopes := apiCfg.middlewareRequireScopes
 
 	// api handlers
 	// chirp-related
 	mux.HandleFunc("GET /api/healthz", handlerReady)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerPostChirp)               // post a chirp
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)                // get all chirps
-	mux.HandleFunc("GET /api/chirps/{chirpid}", apiCfg.handlerGetChirp)       // get a single chirp
-	mux.HandleFunc("DELETE /api/chirps/{chirpid}", apiCfg.handlerDeleteChirp) // delete a chirp
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)                                                // public keys for verifying our tokens
+	mux.HandleFunc("POST /api/chirps", requireScopes(apiCfg.handlerPostChirp, auth.ScopeChirpsWrite))               // post a chirp
+	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)                                                      // get all chirps
+	mux.HandleFunc("GET /api/chirps/{chirpid}", apiCfg.handlerGetChirp)                                             // get a single chirp
+	mux.HandleFunc("DELETE /api/chirps/{chirpid}", requireScopes(apiCfg.handlerDeleteChirp, auth.ScopeChirpsWrite)) // delete a chirp
+	mux.HandleFunc("PUT /api/chirps/{chirpid}", requireScopes(apiCfg.handlerUpdateChirp, auth.ScopeChirpsWrite))    // edit a chirp
+	mux.HandleFunc("PATCH /api/chirps/{chirpid}", requireScopes(apiCfg.handlerUpdateChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/chirps/{chirpid}/revisions", apiCfg.handlerGetChirpRevisions) // edit history of a chirp
+	mux.HandleFunc("GET /api/chirps/{chirpid}/thread", apiCfg.handlerGetThread)            // conversation around a chirp
+	mux.HandleFunc("POST /api/chirps/{chirpid}/likes", requireScopes(apiCfg.handleLikeChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpid}/likes", requireScopes(apiCfg.handleUnlikeChirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/chirps/{chirpid}/rechirp", requireScopes(apiCfg.handleRechirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpid}/rechirp", requireScopes(apiCfg.handleUndoRechirp, auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/search/chirps", apiCfg.handlerSearchChirps)
+	// tag-related
+	mux.HandleFunc("GET /api/tags/trending", apiCfg.handlerGetTrendingTags)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", apiCfg.handlerGetTagChirps)
 	// user-related
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	mux.HandleFunc("POST /api/login", apiCfg.handleLogin)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handleLoginMFA)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handleRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handleRevoke)
-	mux.HandleFunc("PUT /api/users", apiCfg.handleUpdateUser)
+	mux.HandleFunc("POST /api/password-reset/request", apiCfg.handlePasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlePasswordResetConfirm)
+	mux.HandleFunc("POST /api/email/verify", apiCfg.handleVerifyEmail)
+	mux.HandleFunc("POST /api/email/verify/resend", requireScopes(apiCfg.handleResendEmailVerification, auth.ScopeAccountWrite))
+	mux.HandleFunc("POST /api/downscope", requireScopes(apiCfg.handleDownscope)) // reduced scope tokens for other clients
+	mux.HandleFunc("PUT /api/users", requireScopes(apiCfg.handleUpdateUser, auth.ScopeAccountWrite))
+	mux.HandleFunc("GET /api/entitlements", requireScopes(apiCfg.handlerGetEntitlements, auth.ScopeRead)) // what the user's membership allows
+	// two-factor authentication
+	mux.HandleFunc("POST /api/mfa/totp", requireScopes(apiCfg.handleEnrollTOTP, auth.ScopeAccountWrite))
+	mux.HandleFunc("POST /api/mfa/totp/confirm", requireScopes(apiCfg.handleConfirmTOTP, auth.ScopeAccountWrite))
+	mux.HandleFunc("DELETE /api/mfa/totp", requireScopes(apiCfg.handleDisableTOTP, auth.ScopeAccountWrite))
+	// sessions
+	mux.HandleFunc("GET /api/sessions", requireScopes(apiCfg.handlerGetSessions, auth.ScopeRead))
+	mux.HandleFunc("DELETE /api/sessions/{id}", requireScopes(apiCfg.handleEndSession, auth.ScopeAccountWrite))
+	mux.HandleFunc("DELETE /api/sessions", requireScopes(apiCfg.handleEndOtherSessions, auth.ScopeAccountWrite)) // log out everywhere else
+	// personal api tokens
+	mux.HandleFunc("POST /api/tokens", requireScopes(apiCfg.handleCreateAPIToken, auth.ScopeAccountWrite))
+	mux.HandleFunc("GET /api/tokens", requireScopes(apiCfg.handlerGetAPITokens, auth.ScopeRead))
+	mux.HandleFunc("DELETE /api/tokens/{id}", requireScopes(apiCfg.handleRevokeAPIToken, auth.ScopeAccountWrite))
+
+	mux.HandleFunc("POST /api/webhooks", requireScopes(apiCfg.handleCreateWebhook, auth.ScopeAccountWrite))
+	mux.HandleFunc("GET /api/webhooks", requireScopes(apiCfg.handlerGetWebhooks, auth.ScopeRead))
+	mux.HandleFunc("DELETE /api/webhooks/{id}", requireScopes(apiCfg.handleDeleteWebhook, auth.ScopeAccountWrite))
+	mux.HandleFunc("GET /api/webhooks/{id}/deliveries", requireScopes(apiCfg.handlerGetWebhookDeliveries, auth.ScopeRead)) // delivery log of an endpoint
+	// follow-related
+	mux.HandleFunc("POST /api/users/{id}/follow", requireScopes(apiCfg.handleFollowUser, auth.ScopeAccountWrite))
+	mux.HandleFunc("DELETE /api/users/{id}/follow", requireScopes(apiCfg.handleUnfollowUser, auth.ScopeAccountWrite))
+	mux.HandleFunc("GET /api/users/{id}/followers", apiCfg.handleGetFollowers)
+	mux.HandleFunc("GET /api/users/{id}/following", apiCfg.handleGetFollowing)
+	mux.HandleFunc("GET /api/timeline", requireScopes(apiCfg.handlerGetTimeline, auth.ScopeRead)) // chirps of followed users
+	// notification-related
+	mux.HandleFunc("GET /api/notifications", requireScopes(apiCfg.handlerGetNotifications, auth.ScopeRead))
+	mux.HandleFunc("POST /api/notifications/read", requireScopes(apiCfg.handleMarkNotificationsRead, auth.ScopeAccountWrite))
 
 	// admin handlers
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerHits)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	// Everything under /admin/ goes through adminMux and needs at least a moderator,
+	// routes that need more say so with requireRole. Only reset is served without a login.
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /admin/metrics", requireRole(apiCfg.handlerHits, auth.RoleAdmin))
+	adminMux.HandleFunc("PUT /admin/users/{id}/role", requireRole(apiCfg.handleSetUserRole, auth.RoleAdmin))
+	adminMux.HandleFunc("GET /admin/moderation/words", requireRole(apiCfg.handlerGetModerationWords, auth.RoleModerator))
+	adminMux.HandleFunc("PUT /admin/moderation/words/{word}", requireRole(apiCfg.handleSetModerationWord, auth.RoleModerator))
+	adminMux.HandleFunc("DELETE /admin/moderation/words/{word}", requireRole(apiCfg.handleDeleteModerationWord, auth.RoleModerator))
+	adminMux.HandleFunc("GET /admin/moderation/flags", requireRole(apiCfg.handlerGetModerationFlags, auth.RoleModerator))
+	adminMux.HandleFunc("POST /admin/moderation/flags/{id}/review", requireRole(apiCfg.handleReviewModerationFlag, auth.RoleModerator))
+	adminMux.HandleFunc("GET /admin/lockouts", requireRole(apiCfg.handlerGetLoginLockouts, auth.RoleAdmin))
+	adminMux.HandleFunc("DELETE /admin/lockouts/{key}", requireRole(apiCfg.handleClearLoginLockout, auth.RoleAdmin))
+	adminMux.HandleFunc("POST /admin/users/{id}/unlock", requireRole(apiCfg.handleUnlockUser, auth.RoleAdmin))
+	adminMux.HandleFunc("GET /admin/users/{id}/subscription", requireRole(apiCfg.handlerGetUserSubscription, auth.RoleAdmin))
+	adminMux.HandleFunc("POST /admin/webhooks", requireRole(apiCfg.handleCreateGlobalWebhook, auth.RoleAdmin)) // endpoint for the events of every user
+	adminMux.HandleFunc("GET /admin/webhooks/dead-letters", requireRole(apiCfg.handlerGetDeadWebhookDeliveries, auth.RoleAdmin))
+	adminMux.HandleFunc("POST /admin/webhooks/deliveries/{id}/retry", requireRole(apiCfg.handleRetryWebhookDelivery, auth.RoleAdmin))
+	mux.Handle("/admin/", apiCfg.middlewareRequireStaff(adminMux))
+	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset) // only with PLATFORM=dev
 
 	// webhooks
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handleMakeUserRed)
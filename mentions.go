@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/chirptext"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const notificationKindMention = "mention"
+
+// mentionEntity is a resolved @username in a chirp body.
+// Start and End are character offsets into the body, End is exclusive.
+type mentionEntity struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Start    int32     `json:"start"`
+	End      int32     `json:"end"`
+}
+
+// indexChirpMentions (re)builds the mentions of a chirp from its body.
+// Users mentioned for the first time get notified, the author never is.
+// It's meant to run in the same transaction that writes the body.
+func indexChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	previous, err := q.GetMentionedUserIDs(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	if err := q.ClearChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	mentions := chirptext.ExtractMentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+	names := make([]string, len(mentions))
+	for i, m := range mentions {
+		names[i] = chirptext.NormalizeUsername(m.Username)
+	}
+	users, err := q.GetUsersByUsernames(ctx, names)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		byName[chirptext.NormalizeUsername(user.Username.String)] = user.ID
+	}
+
+	notified := map[uuid.UUID]bool{chirp.UserID: true}
+	for _, uid := range previous {
+		notified[uid] = true
+	}
+	for _, m := range mentions {
+		// Tokens that don't match any user are left as plain text
+		uid, ok := byName[chirptext.NormalizeUsername(m.Username)]
+		if !ok {
+			continue
+		}
+		err := q.CreateMention(ctx, database.CreateMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      uid,
+			StartOffset: int32(m.Start),
+			EndOffset:   int32(m.End),
+		})
+		if err != nil {
+			return err
+		}
+		if notified[uid] {
+			continue
+		}
+		err = q.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  uid,
+			Kind:    notificationKindMention,
+			ActorID: chirp.UserID,
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		notified[uid] = true
+	}
+	return nil
+}
+
+// getChirpMentions looks up the resolved mentions of several chirps at once
+func (cfg *apiConfig) getChirpMentions(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]mentionEntity, error) {
+	rows, err := cfg.db.GetMentionsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	mentions := make(map[uuid.UUID][]mentionEntity)
+	for _, row := range rows {
+		mentions[row.ChirpID] = append(mentions[row.ChirpID], mentionEntity{
+			UserID:   row.UserID,
+			Username: row.Username.String,
+			Start:    row.StartOffset,
+			End:      row.EndOffset,
+		})
+	}
+	return mentions, nil
+}
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	// The user's notifications, newest first.
+	// The next page is fetched by passing next_cursor as "before".
+	type notificationResponse struct {
+		ID        uuid.UUID     `json:"id"`
+		CreatedAt time.Time     `json:"created_at"`
+		Kind      string        `json:"kind"`
+		ActorID   uuid.UUID     `json:"actor_id"`
+		ChirpID   uuid.NullUUID `json:"chirp_id"`
+		Read      bool          `json:"read"`
+	}
+	type notificationsPage struct {
+		Notifications []notificationResponse `json:"notifications"`
+		NextCursor    string                 `json:"next_cursor,omitempty"`
+	}
+
+	inUID := requestUserID(r)
+
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := cfg.db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:          inUID,
+		BeforeCreatedAt: page.Before.CreatedAt,
+		BeforeID:        page.Before.ID,
+		MaxRows:         page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting notifications from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := notificationsPage{Notifications: []notificationResponse{}}
+	notifications, resp.NextCursor = pagination.Trim(notifications, page.Limit, func(n database.Notification) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	})
+	for _, n := range notifications {
+		resp.Notifications = append(resp.Notifications, notificationResponse{
+			ID:        n.ID,
+			CreatedAt: n.CreatedAt,
+			Kind:      n.Kind,
+			ActorID:   n.ActorID,
+			ChirpID:   n.ChirpID,
+			Read:      n.ReadAt.Valid,
+		})
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	inUID := requestUserID(r)
+
+	if _, err := cfg.db.MarkNotificationsRead(r.Context(), inUID); err != nil {
+		log.Printf("Error marking notifications as read: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// The password step of a login with 2FA has to be followed up within five minutes
+const mfaChallengeLifetime = 5 * time.Minute
+
+// A challenge can be answered five times, then the password has to be entered again
+const maxMFAAttempts = 5
+
+// Users get ten recovery codes when they turn 2FA on
+const recoveryCodeCount = 10
+
+// totpIssuer is the name authenticator apps show next to chirpy codes
+const totpIssuer = "Chirpy"
+
+// respondMFAChallenge answers the password step of a login when the user has 2FA on
+func (cfg *apiConfig) respondMFAChallenge(w http.ResponseWriter, r *http.Request, user database.User) {
+	type mfaChallengeResponse struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+
+	if err := cfg.db.DeleteExpiredMFAChallenges(r.Context()); err != nil {
+		log.Printf("Error deleting expired MFA challenges: %s", err)
+	}
+
+	token, err := cfg.jwtKeys.MakeMFAChallenge(user.ID, mfaChallengeLifetime)
+	if err != nil {
+		log.Printf("Error generating MFA challenge: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(mfaChallengeResponse{MFARequired: true, MFAToken: token})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// checkSecondFactor checks a TOTP code or a recovery code of a user with 2FA on.
+// Either one is used up when it's accepted.
+func (cfg *apiConfig) checkSecondFactor(r *http.Request, user database.User, code string) (bool, error) {
+	if !user.TotpSecret.Valid {
+		return false, nil
+	}
+
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now(), user.TotpLastStep)
+	if ok {
+		// The step is only moved forward, two requests can't both use the same code
+		used, err := cfg.db.UseTOTPStep(r.Context(), database.UseTOTPStepParams{ID: user.ID, TotpLastStep: step})
+		if err != nil {
+			return false, err
+		}
+		return used > 0, nil
+	}
+
+	// Every recovery code is checked against a slow hash, so only what could be one is
+	code = auth.NormalizeRecoveryCode(code)
+	if !auth.IsRecoveryCode(code) {
+		return false, nil
+	}
+	codes, err := cfg.db.GetUnusedRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, recovery := range codes {
+		match, err := auth.CheckPasswordHash(code, recovery.CodeHash)
+		if err != nil {
+			return false, err
+		}
+		if !match {
+			continue
+		}
+		used, err := cfg.db.UseRecoveryCode(r.Context(), recovery.ID)
+		if err != nil {
+			return false, err
+		}
+		if used > 0 {
+			logSecurityEvent(r, "recovery_code_used", user.ID, fmt.Sprintf("%d left", len(codes)-1))
+		}
+		return used > 0, nil
+	}
+	return false, nil
+}
+
+func (cfg *apiConfig) handleLoginMFA(w http.ResponseWriter, r *http.Request) {
+	// The second step of a login with 2FA: the challenge of the password step and a code
+	type loginMFARequest struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := loginMFARequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := cfg.jwtKeys.ValidateMFAChallenge(reqBody.MFAToken)
+	if err != nil {
+		respondTokenError(w, err)
+		return
+	}
+	userID := challenge.UserID
+
+	attempts, err := cfg.db.CountMFAChallengeAttempt(r.Context(), database.CountMFAChallengeAttemptParams{
+		ID:        challenge.ID,
+		UserID:    userID,
+		ExpiresAt: challenge.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		log.Printf("Error counting MFA attempts: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if attempts > maxMFAAttempts {
+		respondError(w, "Too many wrong codes, log in again", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error looking up user %s in database: %s", userID, err)
+		respondError(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondError(w, "2FA is not enabled", http.StatusUnauthorized)
+		return
+	}
+
+	// Wrong codes count as failed logins, or six digits could be guessed within the challenge's lifetime
+	accountKey := accountLoginKey(user.Email)
+	if !cfg.checkLoginAllowed(w, r, accountKey, clientLoginKey(r)) {
+		return
+	}
+
+	ok, err := cfg.checkSecondFactor(r, user, reqBody.Code)
+	if err != nil {
+		log.Printf("Error checking second factor: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		logSecurityEvent(r, "mfa_failed", user.ID, "login")
+		if err := cfg.recordLoginFailure(r, accountKey); err != nil {
+			log.Printf("Error recording failed login: %s", err)
+		}
+		respondError(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+func (cfg *apiConfig) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	// Starts turning 2FA on. The secret is handed out once, 2FA is on after it's confirmed with a code.
+	// Enrolling again before confirming replaces the secret.
+	type enrollResponse struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	set, err := cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error saving TOTP secret: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if set == 0 {
+		respondError(w, "2FA is already enabled", http.StatusConflict)
+		return
+	}
+
+	dat, err := json.Marshal(enrollResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	// Turns 2FA on once the user shows their authenticator works.
+	// The recovery codes are returned here and never again.
+	type confirmRequest struct {
+		Code string `json:"code"`
+	}
+	type confirmResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := confirmRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondError(w, "2FA is already enabled", http.StatusConflict)
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondError(w, "No 2FA enrollment to confirm", http.StatusBadRequest)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, reqBody.Code, time.Now(), user.TotpLastStep)
+	if !ok {
+		respondError(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("Error generating recovery codes: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	enabled, err := qtx.EnableTOTP(r.Context(), database.EnableTOTPParams{ID: user.ID, TotpLastStep: step})
+	if err != nil {
+		log.Printf("Error enabling 2FA: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if enabled == 0 {
+		respondError(w, "2FA is already enabled", http.StatusConflict)
+		return
+	}
+	if err := replaceRecoveryCodes(r, qtx, user.ID, codes); err != nil {
+		log.Printf("Error saving recovery codes: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing 2FA enrollment: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logSecurityEvent(r, "mfa_enabled", user.ID, "totp")
+
+	dat, err := json.Marshal(confirmResponse{RecoveryCodes: codes})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// replaceRecoveryCodes stores the hashes of a user's new recovery codes, the old ones stop working
+func replaceRecoveryCodes(r *http.Request, q *database.Queries, userID uuid.UUID, codes []string) error {
+	if err := q.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		hash, err := auth.HashPassword(code)
+		if err != nil {
+			return err
+		}
+		err = q.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{UserID: userID, CodeHash: hash})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	// Turns 2FA off. A stolen access token isn't enough, it takes a code or a recovery code.
+	type disableRequest struct {
+		Code string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := disableRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	// An enrollment that was never confirmed can be dropped without a code
+	if user.TotpEnabledAt.Valid {
+		ok, err := cfg.checkSecondFactor(r, user, reqBody.Code)
+		if err != nil {
+			log.Printf("Error checking second factor: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			logSecurityEvent(r, "mfa_failed", user.ID, "disable")
+			respondError(w, "Invalid code", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.DisableTOTP(r.Context(), user.ID); err != nil {
+		log.Printf("Error disabling 2FA: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), user.ID); err != nil {
+		log.Printf("Error deleting recovery codes: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing 2FA removal: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		logSecurityEvent(r, "mfa_disabled", user.ID, "totp")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
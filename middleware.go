@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+	roleKey
+)
+
+// middlewareRequireScopes only lets requests through with an access token granting all the scopes.
+// The handler finds who the token belongs to with requestIdentity.
+func (cfg *apiConfig) middlewareRequireScopes(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			log.Printf("Error extracting jwt from header: %s", err)
+			respondError(w, "Authentification failed", http.StatusUnauthorized)
+			return
+		}
+		id, err := cfg.authenticate(r.Context(), token)
+		var tokenErr *auth.TokenError
+		if errors.As(err, &tokenErr) {
+			respondTokenError(w, err)
+			return
+		} else if err != nil {
+			log.Printf("Error authenticating request: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		if !id.HasScopes(scopes...) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"insufficient_scope\", scope=%q", strings.Join(scopes, " ")))
+			respondError(w, "Token doesn't allow this operation", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), identityKey, id)))
+	}
+}
+
+// authenticate checks a bearer token, which is either a JWT or a personal API token.
+// Errors about the token itself are *auth.TokenError values.
+func (cfg *apiConfig) authenticate(ctx context.Context, token string) (auth.Identity, error) {
+	if !auth.IsAPIToken(token) {
+		id, err := cfg.jwtKeys.Authenticate(token)
+		if err != nil || id.SessionID == uuid.Nil {
+			return id, err
+		}
+		// Access tokens die with their session, not only when they expire
+		session, err := cfg.db.GetSession(ctx, id.SessionID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && session.RevokedAt.Valid) {
+			return auth.Identity{}, &auth.TokenError{Kind: auth.ErrTokenRevoked, Err: errors.New("session ended")}
+		}
+		return id, err
+	}
+
+	apiToken, err := cfg.db.GetAPITokenByHash(ctx, auth.HashAPIToken(token))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && apiToken.RevokedAt.Valid) {
+		return auth.Identity{}, &auth.TokenError{Kind: auth.ErrTokenRevoked, Err: errors.New("api token unknown or revoked")}
+	} else if err != nil {
+		return auth.Identity{}, err
+	}
+	if apiToken.ExpiresAt.Valid && time.Now().UTC().After(apiToken.ExpiresAt.Time) {
+		return auth.Identity{}, &auth.TokenError{Kind: auth.ErrTokenExpired, Err: errors.New("api token is expired")}
+	}
+	if err := cfg.db.TouchAPIToken(ctx, apiToken.ID); err != nil {
+		log.Printf("Error recording api token use: %s", err)
+	}
+
+	return auth.Identity{
+		UserID:    apiToken.UserID,
+		Scopes:    apiToken.Scopes,
+		ExpiresAt: apiToken.ExpiresAt.Time,
+		APIToken:  true,
+	}, nil
+}
+
+// requestIdentity returns who the access token of a request was issued to.
+// It's only available to handlers behind middlewareRequireScopes.
+func requestIdentity(r *http.Request) auth.Identity {
+	id, ok := r.Context().Value(identityKey).(auth.Identity)
+	if !ok {
+		panic("requestIdentity called without middlewareRequireScopes")
+	}
+	return id
+}
+
+// requestUserID returns the id of the user making an authenticated request
+func requestUserID(r *http.Request) uuid.UUID {
+	return requestIdentity(r).UserID
+}
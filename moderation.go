@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/moderation"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+// reloadModeration rebuilds the moderator's rules from the base list and the words in the database
+func (cfg *apiConfig) reloadModeration(ctx context.Context) error {
+	words, err := cfg.db.ListModerationWords(ctx)
+	if err != nil {
+		return err
+	}
+	rules := append([]moderation.Rule{}, cfg.moderationBase...)
+	for _, word := range words {
+		action, err := moderation.ParseAction(word.Action)
+		if err != nil {
+			log.Printf("Skipping moderated word %q: %s", word.Word, err)
+			continue
+		}
+		rules = append(rules, moderation.Rule{Word: word.Word, Action: action})
+	}
+	cfg.moderator.Replace(rules)
+	return nil
+}
+
+// flagChirp queues a chirp for review if moderation flagged it
+func flagChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID, res moderation.Result) error {
+	if res.Action != moderation.Flag {
+		return nil
+	}
+	words := []string{}
+	for _, m := range res.Matches {
+		if m.Action == moderation.Flag {
+			words = append(words, m.Word)
+		}
+	}
+	return q.CreateModerationFlag(ctx, database.CreateModerationFlagParams{
+		ChirpID: chirpID,
+		Words:   words,
+	})
+}
+
+type moderationWordResponse struct {
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (cfg *apiConfig) handlerGetModerationWords(w http.ResponseWriter, r *http.Request) {
+	// Only the words set through the admin api are listed, not the base list
+	words, err := cfg.db.ListModerationWords(r.Context())
+	if err != nil {
+		log.Printf("Error getting moderated words from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := []moderationWordResponse{}
+	for _, word := range words {
+		resp = append(resp, moderationWordResponse{
+			Word:      word.Word,
+			Action:    word.Action,
+			UpdatedAt: word.UpdatedAt,
+		})
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleSetModerationWord(w http.ResponseWriter, r *http.Request) {
+	// Adds a word or changes its action. Setting "allow" exempts a word of the base list.
+	type setWordRequest struct {
+		Action string `json:"action"`
+	}
+
+	word := moderation.Normalize(r.PathValue("word"))
+	if word == "" {
+		respondError(w, "No word given", http.StatusBadRequest)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := setWordRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+	action, err := moderation.ParseAction(reqBody.Action)
+	if err != nil {
+		respondError(w, "Unknown moderation action", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := cfg.db.SetModerationWord(r.Context(), database.SetModerationWordParams{
+		Word:   word,
+		Action: action.String(),
+	})
+	if err != nil {
+		log.Printf("Error saving moderated word: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		log.Printf("Error reloading moderated words: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(moderationWordResponse{
+		Word:      saved.Word,
+		Action:    saved.Action,
+		UpdatedAt: saved.UpdatedAt,
+	})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleDeleteModerationWord(w http.ResponseWriter, r *http.Request) {
+	// Words of the base list fall back to their original action
+	deleted, err := cfg.db.DeleteModerationWord(r.Context(), moderation.Normalize(r.PathValue("word")))
+	if err != nil {
+		log.Printf("Error deleting moderated word: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		respondError(w, "Word not found", http.StatusNotFound)
+		return
+	}
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		log.Printf("Error reloading moderated words: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetModerationFlags(w http.ResponseWriter, r *http.Request) {
+	// Chirps waiting for review, oldest first.
+	// The next page is fetched by passing next_cursor as "after".
+	type flagsPage struct {
+		Flags      []database.ModerationFlag `json:"flags"`
+		NextCursor string                    `json:"next_cursor,omitempty"`
+	}
+
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	flags, err := cfg.db.ListPendingModerationFlags(r.Context(), database.ListPendingModerationFlagsParams{
+		AfterCreatedAt: page.After.CreatedAt,
+		AfterID:        page.After.ID,
+		MaxRows:        page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting moderation flags from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := flagsPage{}
+	resp.Flags, resp.NextCursor = pagination.Trim(flags, page.Limit, func(f database.ModerationFlag) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: f.CreatedAt, ID: f.ID}
+	})
+	if resp.Flags == nil {
+		resp.Flags = []database.ModerationFlag{}
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleReviewModerationFlag(w http.ResponseWriter, r *http.Request) {
+	// Marks a flag as reviewed. Deleting the chirp, if it deserves it, is a separate step.
+	flagID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing flag id", http.StatusBadRequest)
+		return
+	}
+
+	reviewed, err := cfg.db.ReviewModerationFlag(r.Context(), flagID)
+	if err != nil {
+		log.Printf("Error reviewing moderation flag: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if reviewed == 0 {
+		respondError(w, "Pending flag not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
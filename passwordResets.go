@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// Password reset tokens have to be used within an hour
+const passwordResetLifetime = time.Hour
+
+func (cfg *apiConfig) handlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	// E-mails a reset token to the address given, if it belongs to a user.
+	// The response is the same either way, so it can't be used to find out who has an account.
+	type resetRequest struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := resetRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+	if reqBody.Email == "" {
+		respondError(w, "No e-mail provided", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserByEmail(r.Context(), reqBody.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	} else if err != nil {
+		log.Printf("Error looking up user %s in database: %s", reqBody.Email, err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := auth.MakeEmailToken()
+	if err != nil {
+		log.Printf("Error generating reset token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	// Only the newest token works
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.UsePasswordResets(r.Context(), user.ID); err != nil {
+		log.Printf("Error invalidating older reset tokens: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	_, err = qtx.CreatePasswordReset(r.Context(), database.CreatePasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashEmailToken(token),
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	})
+	if err != nil {
+		log.Printf("Error saving reset token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing reset token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	logSecurityEvent(r, "password_reset_requested", user.ID, "")
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new password, use this reset token within an hour:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this e-mail, your password hasn't changed.\n", token),
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	// Sets a new password with a reset token. Every session of the user ends,
+	// whoever knew the old password is logged out.
+	type confirmRequest struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := confirmRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+	if reqBody.Password == "" {
+		respondError(w, "No password provided", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(reqBody.Password)
+	if err != nil {
+		log.Printf("Error hashing password: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// The row stays locked until the token is used, so it can't be used twice
+	reset, err := qtx.GetPasswordResetForUpdate(r.Context(), auth.HashEmailToken(reqBody.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Error getting reset token from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if reset.UsedAt.Valid || time.Now().UTC().After(reset.ExpiresAt) {
+		respondError(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             reset.UserID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Printf("Error updating password: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := qtx.UsePasswordResets(r.Context(), reset.UserID); err != nil {
+		log.Printf("Error using reset token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := endSessionsExcept(r.Context(), qtx, reset.UserID, uuid.Nil); err != nil {
+		log.Printf("Error ending sessions: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	// API tokens could have been created by whoever had the account, they go too
+	if err := qtx.RevokeAllAPITokens(r.Context(), reset.UserID); err != nil {
+		log.Printf("Error revoking api tokens: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing password reset: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logSecurityEvent(r, "password_reset", reset.UserID, "all sessions ended and api tokens revoked")
+
+	user, err := cfg.db.GetUserByID(r.Context(), reset.UserID)
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+	} else {
+		cfg.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Your Chirpy password was changed",
+			Body: "The password of your Chirpy account was just reset. You were logged out everywhere and your API tokens were revoked.\n\n" +
+				"If it wasn't you, reset your password again right away.\n",
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/subscription"
+	"github.com/Denisowiec/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// Signed Polka deliveries are accepted for 5 minutes after they're sent
+const polkaSignatureTolerance = 5 * time.Minute
+
+// Polka webhook bodies are small, anything bigger isn't from Polka
+const maxPolkaBody = 64 << 10
+
+func (cfg *apiConfig) handleMakeUserRed(w http.ResponseWriter, r *http.Request) {
+	// Polka tells us about Chirpy Red subscriptions: upgrades, renewals, failed payments, downgrades and refunds
+	type reqBody struct {
+		// ID identifies the event, a retried delivery has the same one
+		ID    string `json:"id"`
+		Event string `json:"event"`
+		Data  struct {
+			UserID    uuid.UUID  `json:"user_id"`
+			Plan      string     `json:"plan"`
+			PeriodEnd *time.Time `json:"period_end"`
+		} `json:"data"`
+	}
+
+	// Apikey authorization
+	key := auth.GetAPIKey(r.Header)
+	if !auth.APIKeyMatches(key, cfg.polkaApiKey) {
+		log.Println("Error, polka authorization failed.")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// The signature covers the raw body, it has to be read before it's decoded
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolkaBody))
+	if err != nil {
+		log.Printf("Error reading polka webhook: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Unsigned, tampered or replayed deliveries are refused. There's no secret only in development.
+	if len(cfg.polkaWebhookSecret) > 0 || cfg.platform != "dev" {
+		err := webhooks.Verify(cfg.polkaWebhookSecret,
+			r.Header.Get("X-Polka-Timestamp"), r.Header.Get("X-Polka-Signature"),
+			body, time.Now(), polkaSignatureTolerance)
+		if err != nil {
+			logSecurityEvent(r, "webhook_rejected", uuid.Nil, "polka: "+err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	req := reqBody{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ev := subscription.Event{Type: req.Event, Plan: req.Data.Plan}
+	if req.Data.PeriodEnd != nil {
+		ev.PeriodEnd = req.Data.PeriodEnd.UTC()
+	}
+
+	// The event is recorded in the same transaction that applies it,
+	// a delivery that fails can be retried, one that succeeded is only acknowledged
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Deliveries from before Polka sent event ids can't be told apart, they're applied every time
+	if req.ID != "" {
+		recorded, err := qtx.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+			Source:  "polka",
+			EventID: req.ID,
+			Event:   req.Event,
+		})
+		if err != nil {
+			log.Printf("Error recording webhook event: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if recorded == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	// Events we don't know about are acknowledged and otherwise ignored
+	_, err = applySubscriptionEvent(r.Context(), qtx, req.Data.UserID, ev, "polka", req.ID)
+	if errors.Is(err, subscription.ErrUnknownEvent) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("unable to modify user data: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing webhook event: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleRechirp(w http.ResponseWriter, r *http.Request) {
+	// A rechirp is a chirp without a body of its own, pointing at the original.
+	// Each user can rechirp a given chirp only once.
+	inUID := requestUserID(r)
+	if !cfg.checkEmailVerified(w, r, inUID) {
+		return
+	}
+	ent, err := cfg.userEntitlements(r.Context(), inUID)
+	if err != nil {
+		log.Printf("Error getting entitlements: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	reqId, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondError(w, "Error parsing chirp id", http.StatusBadRequest)
+		return
+	}
+
+	original, err := cfg.db.GetChirpById(r.Context(), reqId)
+	if err != nil {
+		respondError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	// Rechirping a rechirp rechirps the original
+	if original.RechirpOf.Valid {
+		original, err = cfg.db.GetChirpById(r.Context(), original.RechirpOf.UUID)
+		if err != nil {
+			respondError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	_, err = cfg.db.GetRechirp(r.Context(), database.GetRechirpParams{
+		UserID:    inUID,
+		RechirpOf: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if err == nil {
+		respondError(w, "Chirp already rechirped", http.StatusConflict)
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error looking up rechirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	// Rechirps count towards the posting rate, checked in the transaction that creates them
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if !checkChirpRate(w, r, qtx, inUID, ent) {
+		return
+	}
+
+	id := uuid.New()
+	rechirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		ID:        id,
+		Body:      "",
+		UserID:    inUID,
+		ThreadID:  id,
+		RechirpOf: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if isUniqueViolation(err) {
+		// Another request rechirped it since the check above
+		respondError(w, "Chirp already rechirped", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("Error putting rechirp into database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing rechirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := cfg.presentChirp(r.Context(), uuid.NullUUID{UUID: inUID, Valid: true}, rechirp)
+	if err != nil {
+		log.Printf("Error preparing chirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleUndoRechirp(w http.ResponseWriter, r *http.Request) {
+	inUID := requestUserID(r)
+
+	reqId, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondError(w, "Error parsing chirp id", http.StatusBadRequest)
+		return
+	}
+
+	rechirp, err := cfg.db.GetRechirp(r.Context(), database.GetRechirpParams{
+		UserID:    inUID,
+		RechirpOf: uuid.NullUUID{UUID: reqId, Valid: true},
+	})
+	if err != nil {
+		respondError(w, "Rechirp not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = cfg.db.DeleteChirp(r.Context(), database.DeleteChirpParams{
+		ID:     rechirp.ID,
+		UserID: inUID,
+	})
+	if err != nil {
+		log.Printf("Error deleting rechirp: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+)
+
+// reduceScopes checks the scopes requested for a new token against the token of the request.
+// A new token may allow less, never more. If the request is refused, the response has been written.
+func reduceScopes(w http.ResponseWriter, id auth.Identity, requested []string) ([]string, bool) {
+	scopes := []string{}
+	for _, scope := range requested {
+		if _, err := auth.ParseScopes(scope); err != nil {
+			respondError(w, "Unknown scope", http.StatusBadRequest)
+			return nil, false
+		}
+		if !id.HasScopes(scope) {
+			respondError(w, "Scopes can only be reduced", http.StatusForbidden)
+			return nil, false
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		respondError(w, "No scopes requested", http.StatusBadRequest)
+		return nil, false
+	}
+	return scopes, true
+}
+
+func (cfg *apiConfig) handleDownscope(w http.ResponseWriter, r *http.Request) {
+	// Mints a token with fewer scopes, to hand to a client that shouldn't get full access.
+	// The new token can't allow more, or live longer, than the one it's minted with.
+	type downscopeRequest struct {
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	type downscopeResponse struct {
+		Token     string    `json:"token"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	id := requestIdentity(r)
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := downscopeRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	scopes, ok := reduceScopes(w, id, reqBody.Scopes)
+	if !ok {
+		return
+	}
+
+	// API tokens may never expire, their reduced tokens last as long as access tokens
+	expiresIn := accessTokenLifetime
+	if !id.ExpiresAt.IsZero() {
+		expiresIn = min(expiresIn, time.Until(id.ExpiresAt))
+	}
+	if reqBody.ExpiresInSeconds < 0 {
+		respondError(w, "Invalid expiry", http.StatusBadRequest)
+		return
+	} else if reqBody.ExpiresInSeconds > 0 {
+		expiresIn = min(expiresIn, time.Duration(reqBody.ExpiresInSeconds)*time.Second)
+	}
+
+	// The reduced token belongs to the same session, ending the session ends it too
+	token, err := cfg.jwtKeys.MakeAccessToken(auth.Identity{
+		UserID:    id.UserID,
+		Scopes:    scopes,
+		SessionID: id.SessionID,
+	}, expiresIn)
+	if err != nil {
+		log.Printf("Error generating JWT: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(downscopeResponse{
+		Token:     token,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(expiresIn).UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+	"github.com/Denisowiec/Chirpy/internal/search"
+)
+
+// searchResult is a matching chirp with its relevance and the matching
+// parts of its body. Matched words in the snippet are wrapped in <mark> tags.
+type searchResult struct {
+	chirpResponse
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchPage struct {
+	Results    []searchResult `json:"results"`
+	NextOffset *int32         `json:"next_offset,omitempty"`
+}
+
+// The database marks where matches start and end with these characters,
+// which it takes out of the body beforehand so a chirp can't fake them
+const (
+	snippetMatchStart = "\x02"
+	snippetMatchEnd   = "\x03"
+)
+
+// escapeSnippet makes a highlighted snippet safe to put in HTML.
+// The body is escaped, then the matches are wrapped in <mark> tags.
+func escapeSnippet(snippet string) string {
+	s := html.EscapeString(snippet)
+	s = strings.ReplaceAll(s, snippetMatchStart, "<mark>")
+	return strings.ReplaceAll(s, snippetMatchEnd, "</mark>")
+}
+
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	// Results are sorted by relevance, which doesn't fit cursors,
+	// so the next page is fetched by passing next_offset as "offset"
+	query := r.URL.Query()
+
+	tsQuery, err := search.BuildTSQuery(query.Get("q"))
+	if errors.Is(err, search.ErrNoTerms) {
+		respondError(w, "No search terms given", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		respondError(w, "Error parsing search query", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+	offset := int32(0)
+	if s := query.Get("offset"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || n < 0 {
+			respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+			return
+		}
+		offset = int32(n)
+	}
+
+	authorID, err := parseAuthorFilter(query)
+	if err != nil {
+		respondError(w, "Error parsing author_id", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:     tsQuery,
+		AuthorID:  authorID,
+		MaxRows:   limit + 1,
+		RowOffset: offset,
+	})
+	if err != nil {
+		log.Printf("Error searching chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := searchPage{Results: []searchResult{}}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		next := offset + limit
+		resp.NextOffset = &next
+	}
+
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+	}
+	presented, err := cfg.presentChirps(r.Context(), cfg.getViewer(r), chirps)
+	if err != nil {
+		log.Printf("Error preparing chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	for i, row := range rows {
+		resp.Results = append(resp.Results, searchResult{
+			chirpResponse: presented[i],
+			Rank:          row.Rank,
+			Snippet:       escapeSnippet(row.Snippet),
+		})
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
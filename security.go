@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// logSecurityEvent records something that may be an attack on an account.
+// The format is fixed, so these lines are easy to find and alert on.
+func logSecurityEvent(r *http.Request, event string, userID uuid.UUID, details string) {
+	log.Printf("SECURITY event=%s user=%s remote=%s details=%q", event, userID, r.RemoteAddr, details)
+}
+
+// clientIP is the address a request came from, as seen by the server
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientUserAgent is the user agent of a request, cut to a sensible length
+func clientUserAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > 256 {
+		ua = ua[:256]
+	}
+	return ua
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// startSession logs a user in from the client making the request.
+// It returns an access token and the first refresh token of the session.
+func (cfg *apiConfig) startSession(r *http.Request, userID uuid.UUID) (string, string, error) {
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	session, err := qtx.CreateSession(r.Context(), database.CreateSessionParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		UserAgent: clientUserAgent(r),
+		Ip:        clientIP(r),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	refToken := auth.MakeRefreshToken()
+	_, err = qtx.SetRefToken(r.Context(), database.SetRefTokenParams{
+		Token:     refToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenLifetime),
+		FamilyID:  session.ID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	token, err := cfg.jwtKeys.MakeAccessToken(auth.Identity{
+		UserID:    userID,
+		Scopes:    auth.AllScopes,
+		SessionID: session.ID,
+	}, accessTokenLifetime)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refToken, nil
+}
+
+// endSession logs a session out: its refresh tokens are revoked and its access tokens stop working.
+// It reports whether the user had such a session still going.
+func endSession(ctx context.Context, q *database.Queries, userID, sessionID uuid.UUID) (bool, error) {
+	ended, err := q.EndSession(ctx, database.EndSessionParams{ID: sessionID, UserID: userID})
+	if err != nil {
+		return false, err
+	}
+	// Only the user's own tokens are revoked, the session id may be someone else's
+	_, err = q.RevokeRefTokenFamily(ctx, database.RevokeRefTokenFamilyParams{FamilyID: sessionID, UserID: userID})
+	if err != nil {
+		return false, err
+	}
+	return ended > 0, nil
+}
+
+// endSessionsExcept ends every session of a user but one. With keep set to uuid.Nil, it ends all of them.
+func endSessionsExcept(ctx context.Context, q *database.Queries, userID, keep uuid.UUID) error {
+	ended, err := q.EndOtherSessions(ctx, database.EndOtherSessionsParams{UserID: userID, KeepID: keep})
+	if err != nil {
+		return err
+	}
+	for _, sessionID := range ended {
+		_, err := q.RevokeRefTokenFamily(ctx, database.RevokeRefTokenFamilyParams{FamilyID: sessionID, UserID: userID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	// Lists where the user is logged in, most recently used first
+	type sessionResponse struct {
+		ID         uuid.UUID `json:"id"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		UserAgent  string    `json:"user_agent"`
+		IP         string    `json:"ip"`
+		Current    bool      `json:"current"`
+	}
+
+	id := requestIdentity(r)
+	sessions, err := cfg.db.ListSessions(r.Context(), id.UserID)
+	if err != nil {
+		log.Printf("Error getting sessions from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := []sessionResponse{}
+	for _, session := range sessions {
+		resp = append(resp, sessionResponse{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IP:         session.Ip,
+			Current:    session.ID == id.SessionID,
+		})
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleEndSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing session id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	ended, err := endSession(r.Context(), cfg.db.WithTx(tx), requestUserID(r), sessionID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Error ending session: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if !ended {
+		respondError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleEndOtherSessions(w http.ResponseWriter, r *http.Request) {
+	// Logs out everywhere except the session making the request.
+	// Requests made with an API token don't belong to a session, so every session ends.
+	id := requestIdentity(r)
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := endSessionsExcept(r.Context(), qtx, id.UserID, id.SessionID); err != nil {
+		log.Printf("Error ending sessions: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing ended sessions: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
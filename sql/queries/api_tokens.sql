@@ -0,0 +1,19 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (created_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (NOW(), $1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash = $1;
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC, id DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1;
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllAPITokens :exec
+UPDATE api_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,5 @@
+-- name: CreateChirpRevision :one
+INSERT INTO chirp_revisions (chirp_id, body, created_at, replaced_at) VALUES ($1, $2, $3, NOW()) RETURNING *;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions WHERE chirp_id = $1 ORDER BY created_at ASC;
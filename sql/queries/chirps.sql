@@ -1,14 +1,64 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (created_at, updated_at, body, user_id) VALUES (NOW(), NOW(), $1, $2) RETURNING *;
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, rechirp_of, quote_of, ancestor_ids)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5, $6, $7, COALESCE(sqlc.narg('ancestor_ids')::uuid[], '{}')) RETURNING *;
 
 -- name: GetChirpById :one
 SELECT * FROM chirps WHERE id = $1;
 
--- name: GetChirpsForUser :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+    AND (created_at, id) > (sqlc.arg('after_created_at')::timestamp, sqlc.arg('after_id')::uuid)
+    AND (created_at, id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('max_rows');
 
--- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+    AND (created_at, id) > (sqlc.arg('after_created_at')::timestamp, sqlc.arg('after_id')::uuid)
+    AND (created_at, id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('max_rows');
 
 -- name: DeleteChirp :one
-DELETE FROM chirps WHERE id = $1 AND user_id = $2 RETURNING *;
\ No newline at end of file
+DELETE FROM chirps WHERE id = $1 AND user_id = $2 RETURNING *;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: GetRechirp :one
+SELECT * FROM chirps WHERE user_id = $1 AND rechirp_of = $2;
+
+-- name: GetChirpByIdForUpdate :one
+SELECT * FROM chirps WHERE id = $1 FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps parent
+    WHERE parent.id = (SELECT child.in_reply_to FROM chirps child WHERE child.id = $1)
+    UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+    FROM chirps parent JOIN ancestors ON parent.id = ancestors.in_reply_to
+)
+SELECT chirps.* FROM chirps JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+-- Replies below deleted chirps are still found through ancestor_ids
+SELECT * FROM chirps
+WHERE ancestor_ids @> ARRAY[sqlc.arg('chirp_id')::uuid]
+    AND (created_at, id) > (sqlc.arg('after_created_at')::timestamp, sqlc.arg('after_id')::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('max_rows');
+
+-- name: AddChirpLikes :one
+UPDATE chirps SET like_count = like_count + sqlc.arg('delta')::integer WHERE id = sqlc.arg('id') RETURNING like_count;
+
+-- name: CountRecentChirps :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND created_at > NOW() - INTERVAL '1 hour';
@@ -0,0 +1,13 @@
+-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (created_at, user_id, email, token_hash, expires_at)
+VALUES (NOW(), $1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetEmailVerificationForUpdate :one
+SELECT * FROM email_verifications WHERE token_hash = $1 FOR UPDATE;
+
+-- name: UseEmailVerifications :exec
+UPDATE email_verifications SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users SET email_verified_at = NOW() WHERE id = $1 AND email = $2 AND email_verified_at IS NULL;
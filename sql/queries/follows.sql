@@ -0,0 +1,27 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at) VALUES ($1, $2, NOW()) ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowers :many
+SELECT follows.follower_id AS user_id, follows.created_at AS followed_at FROM follows
+WHERE follows.followee_id = sqlc.arg('user_id')::uuid
+    AND (follows.created_at, follows.follower_id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: ListFollowing :many
+SELECT follows.followee_id AS user_id, follows.created_at AS followed_at FROM follows
+WHERE follows.follower_id = sqlc.arg('user_id')::uuid
+    AND (follows.created_at, follows.followee_id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: GetTimeline :many
+SELECT chirps.* FROM chirps
+WHERE chirps.user_id IN (SELECT follows.followee_id FROM follows WHERE follows.follower_id = sqlc.arg('user_id')::uuid)
+    AND (chirps.created_at, chirps.id) > (sqlc.arg('after_created_at')::timestamp, sqlc.arg('after_id')::uuid)
+    AND (chirps.created_at, chirps.id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('max_rows');
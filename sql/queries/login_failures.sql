@@ -0,0 +1,21 @@
+-- name: GetLoginFailures :many
+SELECT * FROM login_failures WHERE key = ANY(sqlc.arg('keys')::text[]);
+
+-- name: RecordLoginFailure :one
+-- Failures long enough ago are forgotten, the count starts over
+INSERT INTO login_failures (key, failures, last_failure_at)
+VALUES (sqlc.arg('key'), 1, sqlc.arg('failed_at'))
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE WHEN login_failures.last_failure_at < sqlc.arg('forget_before')::timestamp THEN 1
+        ELSE login_failures.failures + 1 END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING *;
+
+-- name: SetLoginLockout :exec
+UPDATE login_failures SET locked_until = $2 WHERE key = $1;
+
+-- name: ClearLoginFailures :execrows
+DELETE FROM login_failures WHERE key = $1;
+
+-- name: ListLoginLockouts :many
+SELECT * FROM login_failures WHERE locked_until > sqlc.arg('now')::timestamp ORDER BY locked_until DESC;
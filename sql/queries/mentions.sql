@@ -0,0 +1,14 @@
+-- name: CreateMention :exec
+INSERT INTO mentions (chirp_id, user_id, start_offset, end_offset) VALUES ($1, $2, $3, $4);
+
+-- name: ClearChirpMentions :exec
+DELETE FROM mentions WHERE chirp_id = $1;
+
+-- name: GetMentionedUserIDs :many
+SELECT DISTINCT user_id FROM mentions WHERE chirp_id = $1;
+
+-- name: GetMentionsForChirps :many
+SELECT mentions.chirp_id, mentions.user_id, users.username, mentions.start_offset, mentions.end_offset
+FROM mentions JOIN users ON users.id = mentions.user_id
+WHERE mentions.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY mentions.chirp_id, mentions.start_offset;
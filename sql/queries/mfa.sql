@@ -0,0 +1,32 @@
+-- name: SetTOTPSecret :execrows
+UPDATE users SET totp_secret = $2, totp_last_step = 0, updated_at = NOW() WHERE id = $1 AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :execrows
+UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+
+-- name: DisableTOTP :exec
+UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW() WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (created_at, user_id, code_hash) VALUES (NOW(), $1, $2);
+
+-- name: GetUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = NOW() WHERE id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: CountMFAChallengeAttempt :one
+INSERT INTO mfa_challenges (id, user_id, expires_at, attempts) VALUES ($1, $2, $3, 1)
+ON CONFLICT (id) DO UPDATE SET attempts = mfa_challenges.attempts + 1
+RETURNING attempts;
+
+-- name: DeleteExpiredMFAChallenges :exec
+DELETE FROM mfa_challenges WHERE expires_at < NOW();
@@ -0,0 +1,23 @@
+-- name: ListModerationWords :many
+SELECT * FROM moderation_words ORDER BY word;
+
+-- name: SetModerationWord :one
+INSERT INTO moderation_words (word, action, created_at, updated_at) VALUES ($1, $2, NOW(), NOW())
+ON CONFLICT (word) DO UPDATE SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words WHERE word = $1;
+
+-- name: CreateModerationFlag :exec
+INSERT INTO moderation_flags (created_at, chirp_id, words) VALUES (NOW(), $1, $2);
+
+-- name: ListPendingModerationFlags :many
+SELECT * FROM moderation_flags
+WHERE reviewed_at IS NULL
+    AND (created_at, id) > (sqlc.arg('after_created_at')::timestamp, sqlc.arg('after_id')::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('max_rows');
+
+-- name: ReviewModerationFlag :execrows
+UPDATE moderation_flags SET reviewed_at = NOW() WHERE id = $1 AND reviewed_at IS NULL;
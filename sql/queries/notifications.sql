@@ -0,0 +1,12 @@
+-- name: CreateNotification :exec
+INSERT INTO notifications (created_at, user_id, kind, actor_id, chirp_id) VALUES (NOW(), $1, $2, $3, $4);
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg('user_id')
+    AND (created_at, id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL;
@@ -0,0 +1,10 @@
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (created_at, user_id, token_hash, expires_at)
+VALUES (NOW(), $1, $2, $3)
+RETURNING *;
+
+-- name: GetPasswordResetForUpdate :one
+SELECT * FROM password_resets WHERE token_hash = $1 FOR UPDATE;
+
+-- name: UsePasswordResets :exec
+UPDATE password_resets SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL;
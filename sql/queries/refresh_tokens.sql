@@ -1,6 +1,6 @@
 -- name: SetRefToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at,        revoked_at) VALUES 
-                              ($1,      NOW(),      NOW(),      $2, $3, NULL      ) RETURNING *;
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at,        revoked_at, family_id) VALUES 
+                              ($1,      NOW(),      NOW(),      $2, $3, NULL      , $4) RETURNING *;
 
 -- name: GetRefToken :one
 SELECT * FROM refresh_tokens WHERE token = $1;
@@ -9,4 +9,14 @@ SELECT * FROM refresh_tokens WHERE token = $1;
 SELECT user_id FROM refresh_tokens WHERE token = $1;
 
 -- name: RevokeToken :one
-UPDATE refresh_tokens SET revoked_at = Now(), updated_at = Now() WHERE token = $1 RETURNING *;
\ No newline at end of file
+UPDATE refresh_tokens SET revoked_at = Now(), updated_at = Now() WHERE token = $1 RETURNING *;
+
+-- name: GetRefTokenForUpdate :one
+SELECT * FROM refresh_tokens WHERE token = $1 FOR UPDATE;
+
+-- name: ReplaceRefToken :one
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW(), replaced_by = $2 WHERE token = $1 RETURNING *;
+
+-- name: RevokeRefTokenFamily :execrows
+-- Family ids are session ids, which clients know, so the user has to be given too
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
@@ -0,0 +1,12 @@
+-- name: SearchChirps :many
+-- Rechirps have no body of their own and never match. The snippet marks matches with \x02 and \x03,
+-- which are taken out of the body first; the handler turns them into tags after escaping.
+SELECT sqlc.embed(chirps),
+    ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg('query')::text)) AS rank,
+    ts_headline('english', translate(body, E'\x02\x03', ''), to_tsquery('english', sqlc.arg('query')::text),
+        E'StartSel="\x02", StopSel="\x03", MaxFragments=2, FragmentDelimiter=" ... "') AS snippet
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', sqlc.arg('query')::text)
+    AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('max_rows') OFFSET sqlc.arg('row_offset');
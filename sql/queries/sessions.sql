@@ -0,0 +1,21 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, user_agent, ip, last_used_at)
+VALUES ($1, NOW(), $2, $3, $4, NOW())
+RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions WHERE id = $1;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_used_at = NOW(), user_agent = $2, ip = $3 WHERE id = $1;
+
+-- name: ListSessions :many
+SELECT * FROM sessions WHERE user_id = $1 AND revoked_at IS NULL ORDER BY last_used_at DESC, id DESC;
+
+-- name: EndSession :execrows
+UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: EndOtherSessions :many
+UPDATE sessions SET revoked_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND id <> sqlc.arg('keep_id') AND revoked_at IS NULL
+RETURNING id;
@@ -0,0 +1,23 @@
+-- name: GetUserSubscriptionForUpdate :one
+SELECT id, is_chirpy_red, subscription_status, subscription_plan, subscription_period_end
+FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: SetUserSubscription :exec
+UPDATE users SET subscription_status = $2, subscription_plan = $3, subscription_period_end = $4,
+    is_chirpy_red = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (created_at, user_id, source, event_id, event, old_status, new_status, plan, period_end, is_chirpy_red, note)
+VALUES (NOW(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: ListSubscriptionEvents :many
+SELECT * FROM subscription_events WHERE user_id = $1 ORDER BY created_at DESC, id DESC;
+
+-- name: ListLapsedSubscriptions :many
+-- Subscriptions whose period is over. Past due ones are only over after their grace period.
+SELECT id FROM users
+WHERE (subscription_status IN ('active', 'canceled') AND subscription_period_end < sqlc.arg('now')::timestamp)
+    OR (subscription_status = 'past_due' AND subscription_period_end < sqlc.arg('past_due_before')::timestamp)
+ORDER BY subscription_period_end
+LIMIT sqlc.arg('max_users');
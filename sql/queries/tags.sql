@@ -0,0 +1,28 @@
+-- name: UpsertTag :one
+INSERT INTO tags (name, created_at) VALUES ($1, NOW())
+ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+RETURNING *;
+
+-- name: TagChirp :exec
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;
+
+-- name: ClearChirpTags :exec
+DELETE FROM chirp_tags WHERE chirp_id = $1;
+
+-- name: ListChirpsForTag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE tags.name = sqlc.arg('tag')::text
+    AND (chirp_tags.created_at, chirp_tags.chirp_id) > (sqlc.arg('after_created_at')::timestamp, sqlc.arg('after_id')::uuid)
+    AND (chirp_tags.created_at, chirp_tags.chirp_id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY chirp_tags.created_at DESC, chirp_tags.chirp_id DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: GetTrendingTags :many
+SELECT tags.name, COUNT(*) AS chirp_count FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE chirp_tags.created_at > NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT sqlc.arg('max_rows');
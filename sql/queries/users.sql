@@ -1,5 +1,5 @@
 -- name: CreateUser :one
-INSERT INTO users (created_at, updated_at, email, hashed_password) VALUES (NOW(), NOW(), $1, $2) RETURNING *;
+INSERT INTO users (created_at, updated_at, email, hashed_password, username) VALUES (NOW(), NOW(), $1, $2, $3) RETURNING *;
 
 -- name: Reset :exec
 TRUNCATE users CASCADE;
@@ -11,10 +11,23 @@ SELECT * FROM users WHERE email = $1;
 SELECT * FROM users WHERE id = $1;
 
 -- name: UpdateUser :one
-UPDATE users SET email = $2, hashed_password = $3, updated_at = NOW() WHERE id = $1 RETURNING *;
+-- A new address has to be verified again
+UPDATE users SET email = $2, hashed_password = $3, username = COALESCE(sqlc.narg('username'), username),
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at END, updated_at = NOW()
+WHERE id = $1 RETURNING *;
 
--- name: MakeUserRed :one
-UPDATE users SET is_chirpy_red = true WHERE id = $1 RETURNING id, is_chirpy_red;
+-- name: GetUsersByUsernames :many
+SELECT id, username FROM users WHERE LOWER(username) = ANY(sqlc.arg('usernames')::text[]);
 
--- name: MakeUserNotRed :one
-UPDATE users SET is_chirpy_red = false WHERE id = $1 RETURNING id, is_chirpy_red;
\ No newline at end of file
+-- name: UpdateUserPassword :exec
+UPDATE users SET hashed_password = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: LockUser :exec
+-- Serializes what a user does until the end of the transaction
+SELECT id FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: SetUserRole :one
+UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: CountAdmins :one
+SELECT COUNT(*) FROM users WHERE role = 'admin';
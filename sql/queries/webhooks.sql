@@ -0,0 +1,65 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (created_at, user_id, url, secret, events)
+VALUES (NOW(), $1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints WHERE user_id = $1 ORDER BY created_at, id;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookEvent :execrows
+-- Queues an event for every endpoint that wants it: the user's own, and the global ones
+INSERT INTO webhook_deliveries (created_at, endpoint_id, event_id, event, payload, status, next_attempt_at)
+SELECT NOW(), e.id, sqlc.arg('event_id')::uuid, sqlc.arg('event')::text, sqlc.arg('payload')::text, 'pending', sqlc.arg('now')::timestamp
+FROM webhook_endpoints e
+WHERE sqlc.arg('event')::text = ANY(e.events)
+    AND (e.user_id IS NULL OR e.user_id = sqlc.arg('user_id')::uuid);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Due deliveries are pushed back by a lease while they're sent, so another dispatcher doesn't send them too
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg('lease_until')::timestamp
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg('now')::timestamp
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('max_rows')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_attempt_at = $2, delivered_at = $2,
+    last_status_code = $3, last_error = NULL
+WHERE id = $1;
+
+-- name: MarkWebhookFailed :exec
+-- With the attempts used up, the status is 'dead'
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, last_attempt_at = $3, next_attempt_at = $4,
+    last_status_code = $5, last_error = $6
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+    AND (created_at, id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: ListDeadWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE status = 'dead'
+    AND (created_at, id) < (sqlc.arg('before_created_at')::timestamp, sqlc.arg('before_id')::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: RetryWebhookDelivery :execrows
+-- A retried delivery gets all its attempts back
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $2
+WHERE id = $1 AND status IN ('dead', 'delivered');
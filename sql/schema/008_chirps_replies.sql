@@ -0,0 +1,21 @@
+-- +goose Up
+-- in_reply_to has no foreign key on purpose: when a parent is deleted,
+-- its replies keep pointing at it, so threads can show where it used to be.
+ALTER TABLE chirps ADD in_reply_to UUID;
+ALTER TABLE chirps ADD thread_id UUID;
+UPDATE chirps SET thread_id = id;
+ALTER TABLE chirps ALTER COLUMN thread_id SET NOT NULL;
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+CREATE INDEX chirps_thread_id_idx ON chirps (thread_id);
+-- ancestor_ids lists every chirp a reply is below, root first. Unlike following in_reply_to,
+-- it still finds a reply after a chirp between it and the one asked about has been deleted.
+ALTER TABLE chirps ADD ancestor_ids UUID[] NOT NULL DEFAULT '{}';
+CREATE INDEX chirps_ancestor_ids_idx ON chirps USING GIN (ancestor_ids);
+
+-- +goose Down
+DROP INDEX chirps_ancestor_ids_idx;
+ALTER TABLE chirps DROP COLUMN ancestor_ids;
+DROP INDEX chirps_thread_id_idx;
+DROP INDEX chirps_in_reply_to_idx;
+ALTER TABLE chirps DROP COLUMN thread_id;
+ALTER TABLE chirps DROP COLUMN in_reply_to;
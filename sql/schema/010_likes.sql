@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE likes (
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX likes_chirp_id_idx ON likes (chirp_id);
+
+-- The number of likes is kept on the chirp itself, so reading it doesn't need a COUNT(*).
+-- It's changed in the same transaction as the likes table.
+ALTER TABLE chirps ADD like_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN like_count;
+DROP TABLE likes;
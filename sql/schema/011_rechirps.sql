@@ -0,0 +1,16 @@
+-- +goose Up
+-- A rechirp repeats another chirp as it is, so it goes away together with the original.
+ALTER TABLE chirps ADD rechirp_of UUID REFERENCES chirps ON DELETE CASCADE;
+-- A quote adds its own body, so it outlives the quoted chirp. There's no foreign key,
+-- the reference is kept and the quote shows that the original has been deleted.
+ALTER TABLE chirps ADD quote_of UUID;
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_idx ON chirps (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL;
+CREATE INDEX chirps_rechirp_of_idx ON chirps (rechirp_of);
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of);
+
+-- +goose Down
+DROP INDEX chirps_quote_of_idx;
+DROP INDEX chirps_rechirp_of_idx;
+DROP INDEX chirps_user_id_rechirp_of_idx;
+ALTER TABLE chirps DROP COLUMN quote_of;
+ALTER TABLE chirps DROP COLUMN rechirp_of;
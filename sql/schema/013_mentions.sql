@@ -0,0 +1,31 @@
+-- +goose Up
+-- Usernames are optional for existing accounts and unique regardless of case
+ALTER TABLE users ADD username TEXT;
+CREATE UNIQUE INDEX users_username_lower_idx ON users (LOWER(username));
+
+-- Offsets are in characters of the chirp body, end_offset is exclusive
+CREATE TABLE mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset)
+);
+CREATE INDEX mentions_user_id_idx ON mentions (user_id);
+
+CREATE TABLE notifications (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    actor_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps ON DELETE CASCADE,
+    read_at TIMESTAMP
+);
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at, id);
+
+-- +goose Down
+DROP TABLE notifications;
+DROP TABLE mentions;
+DROP INDEX users_username_lower_idx;
+ALTER TABLE users DROP COLUMN username;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Words are stored normalized, the same way the moderation package compares them
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE moderation_flags (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps ON DELETE CASCADE,
+    words TEXT[] NOT NULL,
+    reviewed_at TIMESTAMP
+);
+CREATE INDEX moderation_flags_pending_idx ON moderation_flags (created_at, id) WHERE reviewed_at IS NULL;
+
+-- +goose Down
+DROP TABLE moderation_flags;
+DROP TABLE moderation_words;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Every login starts a family of refresh tokens, each refresh replaces the token with a new one in the family.
+-- Existing tokens become families of their own.
+ALTER TABLE refresh_tokens ADD family_id UUID;
+UPDATE refresh_tokens SET family_id = gen_random_uuid();
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE refresh_tokens ADD replaced_by TEXT;
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN replaced_by;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
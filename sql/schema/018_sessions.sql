@@ -0,0 +1,26 @@
+-- +goose Up
+-- A session is a login: the family of refresh tokens it started shares the session's id
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    user_agent TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- Logins from before sessions existed get one, without a known client
+INSERT INTO sessions (id, created_at, user_id, user_agent, ip, last_used_at, revoked_at)
+SELECT family_id, MIN(created_at), user_id, '', '', MAX(updated_at),
+    CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_family_id_fkey
+    FOREIGN KEY (family_id) REFERENCES sessions ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP CONSTRAINT refresh_tokens_family_id_fkey;
+DROP TABLE sessions;
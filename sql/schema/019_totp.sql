@@ -0,0 +1,31 @@
+-- +goose Up
+-- totp_secret is set at enrollment, 2FA is only on once the user confirmed it with a code (totp_enabled_at).
+-- totp_last_step is the time step of the last code used, no code can be used twice.
+ALTER TABLE users ADD totp_secret TEXT;
+ALTER TABLE users ADD totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Recovery codes are hashed like passwords, each one works once
+CREATE TABLE recovery_codes (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP
+);
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+-- Codes tried against each MFA challenge, a challenge is only good for a few guesses
+CREATE TABLE mfa_challenges (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    attempts INT NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+DROP TABLE mfa_challenges;
+DROP TABLE recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+ALTER TABLE users DROP COLUMN totp_secret;
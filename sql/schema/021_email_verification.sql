@@ -0,0 +1,19 @@
+-- +goose Up
+-- Nobody has proven they own their address yet, existing users can ask for a verification e-mail
+ALTER TABLE users ADD email_verified_at TIMESTAMP;
+
+-- A token verifies the address it was sent to, if the user changes it again the token is useless
+CREATE TABLE email_verifications (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+CREATE INDEX email_verifications_user_id_idx ON email_verifications (user_id);
+
+-- +goose Down
+DROP TABLE email_verifications;
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Failed logins are counted per account ("email:<address>") and per client ("ip:<address>").
+-- Keys of addresses without an account are counted too, so lockouts don't tell which accounts exist.
+CREATE TABLE login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE login_failures;
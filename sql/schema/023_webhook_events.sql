@@ -0,0 +1,13 @@
+-- +goose Up
+-- Webhook deliveries already handled, by the sender's event id.
+-- Senders retry until they get a response, a retried event is acknowledged without applying it twice.
+CREATE TABLE webhook_events (
+    source TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (source, event_id)
+);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- is_chirpy_red follows from the subscription, it's kept as a column for the queries that read it.
+-- Users who are red already keep it without a period end, until they're downgraded.
+ALTER TABLE users ADD subscription_status TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE users ADD subscription_plan TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD subscription_period_end TIMESTAMP;
+UPDATE users SET subscription_status = 'active' WHERE is_chirpy_red;
+
+-- Every change of a subscription, with the event that caused it
+CREATE TABLE subscription_events (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    event_id TEXT,
+    event TEXT NOT NULL,
+    old_status TEXT NOT NULL,
+    new_status TEXT NOT NULL,
+    plan TEXT NOT NULL,
+    period_end TIMESTAMP,
+    is_chirpy_red BOOLEAN NOT NULL,
+    note TEXT NOT NULL
+);
+CREATE INDEX subscription_events_user_id_idx ON subscription_events (user_id, created_at);
+
+-- The sweeper looks for subscriptions whose period is over
+CREATE INDEX users_subscription_period_end_idx ON users (subscription_period_end)
+    WHERE subscription_status IN ('active', 'canceled', 'past_due');
+
+-- +goose Down
+DROP INDEX users_subscription_period_end_idx;
+DROP TABLE subscription_events;
+ALTER TABLE users DROP COLUMN subscription_period_end;
+ALTER TABLE users DROP COLUMN subscription_plan;
+ALTER TABLE users DROP COLUMN subscription_status;
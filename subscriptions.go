@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/subscription"
+	"github.com/google/uuid"
+)
+
+// The sweeper expires at most this many subscriptions per run, the rest wait for the next one
+const subscriptionSweepBatch = 500
+
+// applySubscriptionEvent moves a user's subscription through an event, and keeps is_chirpy_red in line with it.
+// Every event is recorded in the user's subscription history, ignored ones too, with why they were ignored.
+// The user's row is locked, q should be in a transaction.
+func applySubscriptionEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, ev subscription.Event, source, eventID string) (subscription.Subscription, error) {
+	row, err := q.GetUserSubscriptionForUpdate(ctx, userID)
+	if err != nil {
+		return subscription.Subscription{}, err
+	}
+	status, err := subscription.ParseStatus(row.SubscriptionStatus)
+	if err != nil {
+		return subscription.Subscription{}, err
+	}
+	current := subscription.Subscription{
+		Status:    status,
+		Plan:      row.SubscriptionPlan,
+		PeriodEnd: row.SubscriptionPeriodEnd.Time,
+	}
+
+	now := time.Now().UTC()
+	next, err := subscription.Apply(current, ev, now)
+	note := ""
+	if errors.Is(err, subscription.ErrIgnored) {
+		note = "ignored, the subscription was " + string(current.Status)
+	} else if err != nil {
+		return current, err
+	}
+	red := next.Entitled(now)
+	periodEnd := sql.NullTime{Time: next.PeriodEnd, Valid: !next.PeriodEnd.IsZero()}
+
+	if note == "" {
+		err = q.SetUserSubscription(ctx, database.SetUserSubscriptionParams{
+			ID:                    userID,
+			SubscriptionStatus:    string(next.Status),
+			SubscriptionPlan:      next.Plan,
+			SubscriptionPeriodEnd: periodEnd,
+			IsChirpyRed:           red,
+		})
+		if err != nil {
+			return current, err
+		}
+	}
+
+	// Webhook endpoints hear about memberships starting and ending, not about every renewal
+	if red != row.IsChirpyRed {
+		event := webhookUserDowngrade
+		if red {
+			event = webhookUserUpgraded
+		}
+		type membershipChange struct {
+			UserID    uuid.UUID  `json:"user_id"`
+			Status    string     `json:"status"`
+			Plan      string     `json:"plan"`
+			PeriodEnd *time.Time `json:"period_end"`
+		}
+		change := membershipChange{
+			UserID: userID,
+			Status: string(next.Status),
+			Plan:   next.Plan,
+		}
+		if periodEnd.Valid {
+			change.PeriodEnd = &periodEnd.Time
+		}
+		err = enqueueWebhookEvent(ctx, q, userID, event, change)
+		if err != nil {
+			return current, err
+		}
+	}
+
+	err = q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		UserID:      userID,
+		Source:      source,
+		EventID:     sql.NullString{String: eventID, Valid: eventID != ""},
+		Event:       ev.Type,
+		OldStatus:   string(current.Status),
+		NewStatus:   string(next.Status),
+		Plan:        next.Plan,
+		PeriodEnd:   periodEnd,
+		IsChirpyRed: red,
+		Note:        note,
+	})
+	if err != nil {
+		return current, err
+	}
+	return next, nil
+}
+
+// sweepSubscriptions expires the subscriptions whose paid period, and grace period, are over.
+// It returns how many were expired.
+func (cfg *apiConfig) sweepSubscriptions(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	lapsed, err := cfg.db.ListLapsedSubscriptions(ctx, database.ListLapsedSubscriptionsParams{
+		Now:           now,
+		PastDueBefore: now.Add(-subscription.GracePeriod),
+		MaxUsers:      subscriptionSweepBatch,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, userID := range lapsed {
+		tx, err := cfg.dbConn.BeginTx(ctx, nil)
+		if err != nil {
+			return expired, err
+		}
+		next, err := applySubscriptionEvent(ctx, cfg.db.WithTx(tx), userID, subscription.Event{Type: subscription.Expire}, "sweeper", "")
+		if err == nil {
+			err = tx.Commit()
+		}
+		tx.Rollback()
+		if err != nil {
+			return expired, err
+		}
+		if next.Status == subscription.Expired {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// runSubscriptionSweeper sweeps subscriptions every interval until the context is done
+func (cfg *apiConfig) runSubscriptionSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		expired, err := cfg.sweepSubscriptions(ctx)
+		if err != nil {
+			log.Printf("Error sweeping subscriptions: %s", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d subscriptions", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetUserSubscription(w http.ResponseWriter, r *http.Request) {
+	// For support: a user's subscription, and the events that led to it, newest first
+	type subscriptionEventResponse struct {
+		ID          uuid.UUID  `json:"id"`
+		CreatedAt   time.Time  `json:"created_at"`
+		Source      string     `json:"source"`
+		EventID     *string    `json:"event_id"`
+		Event       string     `json:"event"`
+		OldStatus   string     `json:"old_status"`
+		NewStatus   string     `json:"new_status"`
+		Plan        string     `json:"plan"`
+		PeriodEnd   *time.Time `json:"period_end"`
+		IsChirpyRed bool       `json:"is_chirpy_red"`
+		Note        string     `json:"note"`
+	}
+	type subscriptionResponse struct {
+		UserID      uuid.UUID                   `json:"user_id"`
+		IsChirpyRed bool                        `json:"is_chirpy_red"`
+		Status      string                      `json:"status"`
+		Plan        string                      `json:"plan"`
+		PeriodEnd   *time.Time                  `json:"period_end"`
+		Events      []subscriptionEventResponse `json:"events"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := cfg.db.ListSubscriptionEvents(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting subscription events from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := subscriptionResponse{
+		UserID:      user.ID,
+		IsChirpyRed: user.IsChirpyRed,
+		Status:      user.SubscriptionStatus,
+		Plan:        user.SubscriptionPlan,
+		Events:      make([]subscriptionEventResponse, 0, len(events)),
+	}
+	for _, event := range events {
+		item := subscriptionEventResponse{
+			ID:          event.ID,
+			CreatedAt:   event.CreatedAt,
+			Source:      event.Source,
+			Event:       event.Event,
+			OldStatus:   event.OldStatus,
+			NewStatus:   event.NewStatus,
+			Plan:        event.Plan,
+			IsChirpyRed: event.IsChirpyRed,
+			Note:        event.Note,
+		}
+		if event.EventID.Valid {
+			item.EventID = &event.EventID.String
+		}
+		if event.PeriodEnd.Valid {
+			item.PeriodEnd = &event.PeriodEnd.Time
+		}
+		resp.Events = append(resp.Events, item)
+	}
+	if user.SubscriptionPeriodEnd.Valid {
+		resp.PeriodEnd = &user.SubscriptionPeriodEnd.Time
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/chirptext"
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+)
+
+// indexChirpTags (re)builds the hashtag index of a chirp from its body.
+// It's meant to run in the same transaction that writes the body.
+func indexChirpTags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.ClearChirpTags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	for _, name := range chirptext.ExtractHashtags(chirp.Body) {
+		tag, err := q.UpsertTag(ctx, name)
+		if err != nil {
+			return err
+		}
+		err = q.TagChirp(ctx, database.TagChirpParams{
+			ChirpID:   chirp.ID,
+			TagID:     tag.ID,
+			CreatedAt: chirp.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetTagChirps(w http.ResponseWriter, r *http.Request) {
+	// Chirps with the given hashtag, newest first.
+	// The next page is fetched by passing next_cursor as "before".
+	tag := chirptext.NormalizeTag(r.PathValue("tag"))
+
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := cfg.db.ListChirpsForTag(r.Context(), database.ListChirpsForTagParams{
+		Tag:             tag,
+		AfterCreatedAt:  page.After.CreatedAt,
+		AfterID:         page.After.ID,
+		BeforeCreatedAt: page.Before.CreatedAt,
+		BeforeID:        page.Before.ID,
+		MaxRows:         page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting chirps for tag from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := chirpsPage{}
+	chirps, resp.NextCursor = pagination.Trim(chirps, page.Limit, chirpCursor)
+	resp.Chirps, err = cfg.presentChirps(r.Context(), cfg.getViewer(r), chirps)
+	if err != nil {
+		log.Printf("Error preparing chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerGetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	// Tags used by the most chirps within a sliding window, 24 hours unless "window" says otherwise
+	type trendingTag struct {
+		Tag        string `json:"tag"`
+		ChirpCount int64  `json:"chirp_count"`
+	}
+	type trendingResponse struct {
+		Window string        `json:"window"`
+		Tags   []trendingTag `json:"tags"`
+	}
+
+	window := defaultTrendingWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		var err error
+		window, err = time.ParseDuration(s)
+		if err != nil || window <= 0 || window > maxTrendingWindow {
+			respondError(w, "Error parsing window", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit, err := pagination.ParseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondError(w, "Error parsing limit", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := cfg.db.GetTrendingTags(r.Context(), database.GetTrendingTagsParams{
+		WindowSeconds: window.Seconds(),
+		MaxRows:       limit,
+	})
+	if err != nil {
+		log.Printf("Error getting trending tags from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := trendingResponse{Window: window.String(), Tags: []trendingTag{}}
+	for _, row := range rows {
+		resp.Tags = append(resp.Tags, trendingTag{Tag: row.Name, ChirpCount: row.ChirpCount})
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+// deletedChirp stands in for a chirp that no longer exists,
+// but is still referenced by other chirps
+type deletedChirp struct {
+	ID      uuid.UUID `json:"id"`
+	Deleted bool      `json:"deleted"`
+}
+
+// threadNode is a reply together with those of its own replies
+// that were fetched on the same page
+type threadNode struct {
+	chirpResponse
+	Replies []*threadNode `json:"replies"`
+}
+
+// buildReplyTree arranges a page of replies, sorted oldest first, into a tree.
+// Replies whose parent isn't on the page (it's the requested chirp itself, it's on
+// an earlier page or it's been deleted) end up at the top level. Clients can use
+// in_reply_to to attach them.
+func buildReplyTree(replies []chirpResponse) []*threadNode {
+	roots := []*threadNode{}
+	nodes := make(map[uuid.UUID]*threadNode, len(replies))
+	for _, reply := range replies {
+		node := &threadNode{chirpResponse: reply, Replies: []*threadNode{}}
+		nodes[reply.ID] = node
+		if parent, ok := nodes[reply.InReplyTo.UUID]; ok && reply.InReplyTo.Valid {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+func (cfg *apiConfig) handlerGetThread(w http.ResponseWriter, r *http.Request) {
+	// Returns the chain of chirps a chirp replies to, and a page of the replies below it.
+	// Replies are paginated oldest first, the next page is fetched with "after".
+	type threadResponse struct {
+		Ancestors  []any         `json:"ancestors"`
+		Chirp      chirpResponse `json:"chirp"`
+		Replies    []*threadNode `json:"replies"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}
+
+	reqId, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		log.Printf("Error parsing chirp id: %s", err)
+		respondError(w, "Error parsing chirp id", http.StatusBadRequest)
+		return
+	}
+
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpById(r.Context(), reqId)
+	if err != nil {
+		respondError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	ancestors, err := cfg.db.GetChirpAncestors(r.Context(), chirp.ID)
+	if err != nil {
+		log.Printf("Error getting chirp ancestors from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	replies, err := cfg.db.GetChirpDescendants(r.Context(), database.GetChirpDescendantsParams{
+		ChirpID:        chirp.ID,
+		AfterCreatedAt: page.After.CreatedAt,
+		AfterID:        page.After.ID,
+		MaxRows:        page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting chirp replies from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	// Everything is presented in one go, so likes are looked up once for the whole thread
+	resp := threadResponse{Ancestors: []any{}}
+	replies, resp.NextCursor = pagination.Trim(replies, page.Limit, chirpCursor)
+	all := append(append([]database.Chirp{chirp}, ancestors...), replies...)
+	presented, err := cfg.presentChirps(r.Context(), cfg.getViewer(r), all)
+	if err != nil {
+		log.Printf("Error preparing chirps: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	resp.Chirp = presented[0]
+	presentedAncestors := presented[1 : 1+len(ancestors)]
+	resp.Replies = buildReplyTree(presented[1+len(ancestors):])
+
+	// The chain of ancestors stops at the first deleted one, which we show as such
+	missing := chirp.InReplyTo
+	if len(ancestors) > 0 {
+		missing = ancestors[0].InReplyTo
+	}
+	if missing.Valid {
+		resp.Ancestors = append(resp.Ancestors, deletedChirp{ID: missing.UUID, Deleted: true})
+	}
+	for _, ancestor := range presentedAncestors {
+		resp.Ancestors = append(resp.Ancestors, ancestor)
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
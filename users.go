@@ -1,28 +1,60 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/Denisowiec/Chirpy/internal/auth"
+	"github.com/Denisowiec/Chirpy/internal/chirptext"
 	"github.com/Denisowiec/Chirpy/internal/database"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// Access tokens last an hour, clients get new ones with their refresh token
+const accessTokenLifetime = time.Hour
+
+// Refresh tokens last 60 days. Every refresh replaces the token, so an active session never expires.
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// isUniqueViolation reports whether a database error was caused by a unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// parseUsername validates an optional username from a request.
+// An empty username means none was given.
+func parseUsername(username string) (sql.NullString, bool) {
+	if username == "" {
+		return sql.NullString{}, true
+	}
+	if !chirptext.ValidUsername(username) {
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: username, Valid: true}, true
+}
+
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	type createUserRequest struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Username string `json:"username"`
 	}
 
 	type createUserResponse struct {
-		ID        uuid.UUID `json:"id"`
-		Email     string    `json:"email"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		IsUserRed bool      `json:"is_chirpy_red"`
+		ID            uuid.UUID `json:"id"`
+		Email         string    `json:"email"`
+		Username      string    `json:"username"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		IsUserRed     bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	reqBody := createUserRequest{}
@@ -35,11 +67,22 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	email, ok := parseEmail(reqBody.Email)
+	if !ok {
+		respondError(w, "Invalid e-mail address", http.StatusBadRequest)
+		return
+	}
 	if reqBody.Password == "" {
 		respondError(w, "No password provided", http.StatusBadRequest)
 		return
 	}
 
+	username, ok := parseUsername(reqBody.Username)
+	if !ok {
+		respondError(w, "Usernames are 3 to 30 letters, digits or underscores", http.StatusBadRequest)
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(reqBody.Password)
 	if err != nil {
 		log.Printf("Error hashing password: %s", err)
@@ -48,26 +91,37 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	}
 
 	crUsParams := database.CreateUserParams{
-		Email:          reqBody.Email,
+		Email:          email,
 		HashedPassword: hashedPassword,
+		Username:       username,
 	}
 
 	user, err := cfg.db.CreateUser(r.Context(), crUsParams)
-	if err != nil {
+	if isUniqueViolation(err) {
+		respondError(w, "Username or e-mail already taken", http.StatusConflict)
+		return
+	} else if err != nil {
 		log.Printf("Error creating user: %s", err)
 		respondError(w, "Could not create user", http.StatusBadRequest)
 		return
 	}
 
+	// The account works without a verified address, unless posting requires one
+	if err := cfg.sendEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Error sending verification e-mail: %s", err)
+	}
+
 	w.WriteHeader(http.StatusCreated) // Code 201
 
 	// We send back the user's info, but withouot the password hash
 	resp := createUserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		IsUserRed: user.IsChirpyRed,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username.String,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		IsUserRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
 	dat, err := json.Marshal(resp)
@@ -80,31 +134,25 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	// Handle PUT request on users, allows updating email and password
+	// Handle PUT request on users, allows updating email and password.
+	// The username is only changed when one is given.
 	type UpdateUserRequest struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Username string `json:"username"`
 	}
 
 	type UpdateUserResponse struct {
-		ID        uuid.UUID `json:"id"`
-		Email     string    `json:"email"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		IsUserRed bool      `json:"is_chirpy_red"`
+		ID            uuid.UUID `json:"id"`
+		Email         string    `json:"email"`
+		Username      string    `json:"username"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		IsUserRed     bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error extracting jwt from header: %s", err)
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
-		return
-	}
-	inUID, err := auth.ValidateJWT(token, cfg.jwtSecretCode)
-	if err != nil {
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
-		return
-	}
+	inUID := requestUserID(r)
 
 	decoder := json.NewDecoder(r.Body)
 	reqBody := UpdateUserRequest{}
@@ -114,6 +162,25 @@ func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	email, ok := parseEmail(reqBody.Email)
+	if !ok {
+		respondError(w, "Invalid e-mail address", http.StatusBadRequest)
+		return
+	}
+
+	username, ok := parseUsername(reqBody.Username)
+	if !ok {
+		respondError(w, "Usernames are 3 to 30 letters, digits or underscores", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := cfg.db.GetUserByID(r.Context(), inUID)
+	if err != nil {
+		log.Printf("Error getting user from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
 	newPassword, err := auth.HashPassword(reqBody.Password)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
@@ -123,24 +190,36 @@ func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	UUParams := database.UpdateUserParams{
 		ID:             inUID,
-		Email:          reqBody.Email,
+		Email:          email,
 		HashedPassword: newPassword,
+		Username:       username,
 	}
 
 	user, err := cfg.db.UpdateUser(r.Context(), UUParams)
-
-	if err != nil {
+	if isUniqueViolation(err) {
+		respondError(w, "Username or e-mail already taken", http.StatusConflict)
+		return
+	} else if err != nil {
 		log.Printf("Error updating user in database: %s", err)
 		respondError(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
+	// A new address is unverified until the user proves they own it
+	if user.Email != previous.Email {
+		if err := cfg.sendEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+			log.Printf("Error sending verification e-mail: %s", err)
+		}
+	}
+
 	respBody := UpdateUserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		IsUserRed: user.IsChirpyRed,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username.String,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		IsUserRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	}
 
 	dat, err := json.Marshal(respBody)
@@ -159,16 +238,6 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
-	type loginResponse struct {
-		ID            uuid.UUID `json:"id"`
-		CreatedAt     time.Time `json:"created_at"`
-		UpdatedAt     time.Time `json:"updated_at"`
-		Email         string    `json:"email"`
-		IsUserRed     bool      `json:"is_chirpy_red"`
-		Token         string    `json:"token"`
-		Refresh_token string    `json:"refresh_token"`
-	}
-
 	decoder := json.NewDecoder(r.Body)
 	reqBody := loginRequest{}
 
@@ -187,55 +256,82 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserByEmail(r.Context(), reqBody.Email)
-	if err != nil {
-		log.Printf("Error looking up user %s in database: %s", reqBody.Email, err)
-		respondError(w, "User not found", http.StatusUnauthorized)
+	// Too many failures make the account, or the client, wait before trying again
+	accountKey := accountLoginKey(reqBody.Email)
+	if !cfg.checkLoginAllowed(w, r, accountKey, clientLoginKey(r)) {
 		return
 	}
 
-	match, err := auth.CheckPasswordHash(reqBody.Password, user.HashedPassword)
-	if err != nil {
-		log.Printf("Error comparing password to hash: %s", err)
+	// Unknown users and wrong passwords get the same answer, after the same time,
+	// so logins can't be used to find out who has an account
+	user, err := cfg.db.GetUserByEmail(r.Context(), reqBody.Email)
+	match := false
+	if errors.Is(err, sql.ErrNoRows) {
+		auth.CheckNoPasswordHash(reqBody.Password)
+	} else if err != nil {
+		log.Printf("Error looking up user %s in database: %s", reqBody.Email, err)
 		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	} else {
+		match, err = auth.CheckPasswordHash(reqBody.Password, user.HashedPassword)
+		if err != nil {
+			log.Printf("Error comparing password to hash: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
 	}
 	if !match {
-		respondError(w, "Password incorrect", http.StatusUnauthorized)
+		if err := cfg.recordLoginFailure(r, accountKey); err != nil {
+			log.Printf("Error recording failed login: %s", err)
+		}
+		respondError(w, "Incorrect e-mail or password", http.StatusUnauthorized)
 		return
 	}
-	// We set up an expiration time for the JWT. It's one hour by default
-	expiresIn := 3600 * time.Second
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecretCode, expiresIn)
 
-	if err != nil {
-		log.Printf("Error generating JWT: %s", err)
-		respondError(w, "Something went wrong", http.StatusInternalServerError)
+	// With 2FA on, the password only earns a challenge, answered at /api/login/mfa
+	if user.TotpEnabledAt.Valid {
+		cfg.respondMFAChallenge(w, r, user)
 		return
 	}
 
-	// We create a refresh token, lasting 60 days
-	refToken := auth.MakeRefreshToken()
-	setRefParams := database.SetRefTokenParams{
-		Token:     refToken,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 60),
+	cfg.completeLogin(w, r, user)
+}
+
+// completeLogin logs in a user who passed every check, and writes the response of the login
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	type loginResponse struct {
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		Username      string    `json:"username"`
+		IsUserRed     bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
+		Token         string    `json:"token"`
+		Refresh_token string    `json:"refresh_token"`
 	}
-	_, err = cfg.db.SetRefToken(r.Context(), setRefParams)
+
+	// Every login starts a new session, with its own family of refresh tokens
+	token, refToken, err := cfg.startSession(r, user.ID)
 	if err != nil {
-		log.Printf("Error recording the refresh token in the database: %s", err)
+		log.Printf("Error starting session: %s", err)
 		respondError(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	// The account's failed logins are forgiven, the client's aren't: one good password mustn't hide guesses at others
+	if _, err := cfg.db.ClearLoginFailures(r.Context(), accountLoginKey(user.Email)); err != nil {
+		log.Printf("Error clearing failed logins: %s", err)
+	}
 
 	resp := loginResponse{
 		ID:            user.ID,
 		CreatedAt:     user.CreatedAt,
 		UpdatedAt:     user.UpdatedAt,
 		Email:         user.Email,
+		Username:      user.Username.String,
 		IsUserRed:     user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 		Token:         token,
 		Refresh_token: refToken,
 	}
@@ -243,13 +339,30 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 	dat, err := json.Marshal(resp)
 	if err != nil {
 		log.Printf("Error marshalling json: %s", err)
-		w.Write([]byte{})
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	w.Write(dat)
 }
 
+// revokeReplayedToken handles a revoked refresh token being presented again.
+// A revoked token should never come back, if it does it has probably been stolen,
+// and we can't tell if it's the thief or the user holding the newest token of the family,
+// so the whole family is revoked, ending the session.
+func revokeReplayedToken(r *http.Request, q *database.Queries, token database.RefreshToken) error {
+	if _, err := endSession(r.Context(), q, token.UserID, token.FamilyID); err != nil {
+		return err
+	}
+	logSecurityEvent(r, "refresh_token_reuse", token.UserID, fmt.Sprintf("session %s ended", token.FamilyID))
+	return nil
+}
+
 func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
-	// This function refreshes the login credentials
+	// This function refreshes the login credentials.
+	// The refresh token presented is used up and replaced by a new one of the same family.
 
 	inRefToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -258,7 +371,17 @@ func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := cfg.db.GetRefToken(r.Context(), inRefToken)
+	// The token stays locked until it's replaced, so it can't be used twice concurrently
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	token, err := qtx.GetRefTokenForUpdate(r.Context(), inRefToken)
 	if err != nil {
 		log.Printf("Error getting token information from database")
 		respondError(w, "Authentification failed", http.StatusUnauthorized)
@@ -266,23 +389,81 @@ func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 	}
 	// If revoked_at is not null, that means the token has been revoked
 	if token.RevokedAt.Valid {
-		log.Printf("Token revoked")
+		err := revokeReplayedToken(r, qtx, token)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("Error revoking token family: %s", err)
+			respondError(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		respondError(w, "Authentification failed", http.StatusUnauthorized)
+		return
+	}
+	if time.Now().UTC().After(token.ExpiresAt) {
+		log.Printf("Token expired")
 		respondError(w, "Authentification failed", http.StatusUnauthorized)
 		return
 	}
 
+	err = qtx.TouchSession(r.Context(), database.TouchSessionParams{
+		ID:        token.FamilyID,
+		UserAgent: clientUserAgent(r),
+		Ip:        clientIP(r),
+	})
+	if err != nil {
+		log.Printf("Error recording session use: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	newRefToken := auth.MakeRefreshToken()
+	_, err = qtx.SetRefToken(r.Context(), database.SetRefTokenParams{
+		Token:     newRefToken,
+		UserID:    token.UserID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenLifetime),
+		FamilyID:  token.FamilyID,
+	})
+	if err != nil {
+		log.Printf("Error recording the refresh token in the database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	_, err = qtx.ReplaceRefToken(r.Context(), database.ReplaceRefTokenParams{
+		Token:      token.Token,
+		ReplacedBy: sql.NullString{String: newRefToken, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error revoking the replaced refresh token: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
 	// If refreshtoken given is valid we offer an access token
-	jwt, err := auth.MakeJWT(token.UserID, cfg.jwtSecretCode, time.Hour)
+	jwt, err := cfg.jwtKeys.MakeAccessToken(auth.Identity{
+		UserID:    token.UserID,
+		Scopes:    auth.AllScopes,
+		SessionID: token.FamilyID,
+	}, accessTokenLifetime)
 	if err != nil {
 		log.Printf("Error creating access token for user: %s", err)
 		respondError(w, "Something went wrong.", http.StatusInternalServerError)
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing token rotation: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
 	var respBody struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	respBody.Token = jwt
+	respBody.RefreshToken = newRefToken
 
 	dat, err := json.Marshal(respBody)
 	if err != nil {
@@ -297,7 +478,7 @@ func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
-	// This function revokes a refresh token
+	// This function revokes a refresh token, logging out of its session
 	inRefToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("Error extracting token from header: %s", err)
@@ -311,15 +492,15 @@ func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 		respondError(w, "Authentification failed", http.StatusUnauthorized)
 		return
 	}
-	// If revoked_at is not null, that means the token has been revoked
+	// Revoking a token that has already been revoked, e.g. logging out twice, is not a replay:
+	// there's nothing left to do
 	if token.RevokedAt.Valid {
-		log.Printf("Token already revoked")
-		respondError(w, "Authentification failed", http.StatusUnauthorized)
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	// If refreshtoken given is valid revoke it
-	_, err = cfg.db.RevokeToken(r.Context(), token.Token)
+	// If refreshtoken given is valid revoke it, with the rest of its session
+	_, err = endSession(r.Context(), &cfg.db, token.UserID, token.FamilyID)
 	if err != nil {
 		log.Printf("Error revoking token: %s", err)
 		respondError(w, "Something went wrong", http.StatusInternalServerError)
@@ -328,42 +509,3 @@ func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
-
-func (cfg *apiConfig) handleMakeUserRed(w http.ResponseWriter, r *http.Request) {
-	type reqBody struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
-
-	// Apikey authorization
-	key := auth.GetAPIKey(r.Header)
-	if key != cfg.polkaApiKey {
-		log.Println("Error, polka authorization failed.")
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	req := reqBody{}
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&req); err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	// We only care about the user.upgraded event. Anything else will be ignored.
-	if req.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	_, err := cfg.db.MakeUserRed(r.Context(), req.Data.UserID)
-	if err != nil {
-		log.Printf("unable to modify user data: %s", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
-}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Denisowiec/Chirpy/internal/database"
+	"github.com/Denisowiec/Chirpy/internal/pagination"
+	"github.com/Denisowiec/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// Events endpoints can subscribe to
+const (
+	webhookChirpCreated  = "chirp.created"
+	webhookChirpDeleted  = "chirp.deleted"
+	webhookUserUpgraded  = "user.upgraded"
+	webhookUserDowngrade = "user.downgraded"
+)
+
+var webhookEvents = []string{webhookChirpCreated, webhookChirpDeleted, webhookUserUpgraded, webhookUserDowngrade}
+
+// The dispatcher sends up to 50 due deliveries at a time, one after the other, giving up on each after 10 seconds.
+// A delivery it took is left alone by other dispatchers until the whole batch could have timed out, and a minute more.
+const (
+	webhookDispatchBatch = 50
+	webhookSendTimeout   = 10 * time.Second
+	webhookLease         = webhookDispatchBatch*webhookSendTimeout + time.Minute
+)
+
+// webhookChirp is a chirp as sent in webhook payloads
+type webhookChirp struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Body      string        `json:"body"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
+}
+
+func newWebhookChirp(chirp database.Chirp) webhookChirp {
+	return webhookChirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UserID:    chirp.UserID,
+		Body:      chirp.Body,
+		InReplyTo: chirp.InReplyTo,
+		QuoteOf:   chirp.QuoteOf,
+	}
+}
+
+// enqueueWebhookEvent queues an event about a user for every endpoint that wants it.
+// It's meant to run in the transaction of the change the event is about, so events of changes that were rolled back are never sent.
+func enqueueWebhookEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, event string, data any) error {
+	type envelope struct {
+		ID        uuid.UUID `json:"id"`
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      any       `json:"data"`
+	}
+
+	now := time.Now().UTC()
+	id := uuid.New()
+	payload, err := json.Marshal(envelope{ID: id, Type: event, CreatedAt: now, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = q.EnqueueWebhookEvent(ctx, database.EnqueueWebhookEventParams{
+		EventID: id,
+		Event:   event,
+		Payload: string(payload),
+		Now:     now,
+		UserID:  userID,
+	})
+	return err
+}
+
+// dispatchWebhooks makes one attempt at every due delivery, and schedules the failed ones for a retry.
+// It returns how many deliveries it attempted.
+func (cfg *apiConfig) dispatchWebhooks(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	due, err := cfg.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: now.Add(webhookLease),
+		Now:        now,
+		MaxRows:    webhookDispatchBatch,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range due {
+		endpoint, err := cfg.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The endpoint was deleted since, its deliveries went with it
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		res := cfg.webhookSender.Send(ctx, webhooks.Delivery{
+			ID:      delivery.ID.String(),
+			URL:     endpoint.Url,
+			Secret:  []byte(endpoint.Secret),
+			Event:   delivery.Event,
+			Payload: []byte(delivery.Payload),
+		})
+		attemptedAt := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		statusCode := sql.NullInt32{Int32: int32(res.StatusCode), Valid: res.StatusCode != 0}
+
+		if res.OK() {
+			err = cfg.db.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+				ID:             delivery.ID,
+				LastAttemptAt:  attemptedAt,
+				LastStatusCode: statusCode,
+			})
+		} else {
+			status := "pending"
+			wait, retry := webhooks.DefaultRetryPolicy.Next(int(delivery.Attempts) + 1)
+			if !retry {
+				status = "dead"
+				log.Printf("Webhook delivery %s to %s is dead after %d attempts: %s", delivery.ID, endpoint.Url, delivery.Attempts+1, res.Err)
+			}
+			err = cfg.db.MarkWebhookFailed(ctx, database.MarkWebhookFailedParams{
+				ID:             delivery.ID,
+				Status:         status,
+				LastAttemptAt:  attemptedAt,
+				NextAttemptAt:  attemptedAt.Time.Add(wait),
+				LastStatusCode: statusCode,
+				LastError:      sql.NullString{String: res.Err.Error(), Valid: true},
+			})
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// runWebhookDispatcher dispatches webhooks every interval until the context is done.
+// A full batch is followed by the next one right away.
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		attempted, err := cfg.dispatchWebhooks(ctx)
+		if err != nil {
+			log.Printf("Error dispatching webhooks: %s", err)
+		}
+		if attempted == webhookDispatchBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// webhookEndpointResponse is an endpoint as shown to its owner. The secret is only shown when it's created.
+type webhookEndpointResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(endpoint database.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+	}
+}
+
+// createWebhookEndpoint registers an endpoint from a request. Endpoints without an owner get every user's events.
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	type createWebhookRequest struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := createWebhookRequest{}
+	if err := decoder.Decode(&reqBody); err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondError(w, "Error decoding parameters", http.StatusBadRequest)
+		return
+	}
+
+	// Payloads can carry private data, only the development setup may send them unencrypted
+	u, err := url.Parse(reqBody.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && !(u.Scheme == "http" && cfg.webhooksAllowPrivate)) {
+		respondError(w, "Webhook URLs have to be https URLs", http.StatusBadRequest)
+		return
+	}
+	events := []string{}
+	for _, event := range reqBody.Events {
+		if !slices.Contains(webhookEvents, event) {
+			respondError(w, "Unknown event "+event, http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		respondError(w, "No events given", http.StatusBadRequest)
+		return
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Printf("Error generating webhook secret: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	secret := "whsec_" + hex.EncodeToString(key)
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: owner,
+		Url:    u.String(),
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		log.Printf("Error saving webhook endpoint: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := newWebhookEndpointResponse(endpoint)
+	resp.Secret = secret
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Registers an endpoint for the events about the user
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{UUID: requestUserID(r), Valid: true})
+}
+
+func (cfg *apiConfig) handleCreateGlobalWebhook(w http.ResponseWriter, r *http.Request) {
+	// Registers an endpoint for the events about every user, for integrations run by admins
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) handlerGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := cfg.db.ListWebhookEndpoints(r.Context(), uuid.NullUUID{UUID: requestUserID(r), Valid: true})
+	if err != nil {
+		log.Printf("Error getting webhook endpoints from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	resp := []webhookEndpointResponse{}
+	for _, endpoint := range endpoints {
+		resp = append(resp, newWebhookEndpointResponse(endpoint))
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// The endpoint's queued deliveries and its delivery log go with it
+	endpointID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing webhook id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: uuid.NullUUID{UUID: requestUserID(r), Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error deleting webhook endpoint: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		respondError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookDeliveryResponse is a delivery attempt log entry, with the payload that was sent
+type webhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func newWebhookDeliveryResponse(delivery database.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:            delivery.ID,
+		CreatedAt:     delivery.CreatedAt,
+		EndpointID:    delivery.EndpointID,
+		EventID:       delivery.EventID,
+		Event:         delivery.Event,
+		Payload:       json.RawMessage(delivery.Payload),
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		NextAttemptAt: delivery.NextAttemptAt,
+		LastError:     delivery.LastError.String,
+	}
+	if delivery.LastAttemptAt.Valid {
+		resp.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		resp.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return resp
+}
+
+// webhookDeliveriesPage is a page of deliveries, newest first.
+// The next page is fetched by passing next_cursor as "before".
+type webhookDeliveriesPage struct {
+	Deliveries []webhookDeliveryResponse `json:"deliveries"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+func respondWebhookDeliveries(w http.ResponseWriter, deliveries []database.WebhookDelivery, limit int32) {
+	resp := webhookDeliveriesPage{}
+	deliveries, resp.NextCursor = pagination.Trim(deliveries, limit, func(d database.WebhookDelivery) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: d.CreatedAt, ID: d.ID}
+	})
+	resp.Deliveries = make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, newWebhookDeliveryResponse(delivery))
+	}
+
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling data: %s", err)
+		respondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// The delivery log of one of the user's endpoints
+	endpointID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing webhook id", http.StatusBadRequest)
+		return
+	}
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil || endpoint.UserID.UUID != requestUserID(r) {
+		respondError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID:      endpoint.ID,
+		BeforeCreatedAt: page.Before.CreatedAt,
+		BeforeID:        page.Before.ID,
+		MaxRows:         page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting webhook deliveries from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	respondWebhookDeliveries(w, deliveries, page.Limit)
+}
+
+func (cfg *apiConfig) handlerGetDeadWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Deliveries that ran out of attempts, of every endpoint
+	page, err := pagination.ParseQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, "Error parsing pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := cfg.db.ListDeadWebhookDeliveries(r.Context(), database.ListDeadWebhookDeliveriesParams{
+		BeforeCreatedAt: page.Before.CreatedAt,
+		BeforeID:        page.Before.ID,
+		MaxRows:         page.Limit + 1,
+	})
+	if err != nil {
+		log.Printf("Error getting webhook deliveries from database: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	respondWebhookDeliveries(w, deliveries, page.Limit)
+}
+
+func (cfg *apiConfig) handleRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	// Sends a dead, or already delivered, delivery again, with all its attempts
+	deliveryID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, "Error parsing delivery id", http.StatusBadRequest)
+		return
+	}
+
+	retried, err := cfg.db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:            deliveryID,
+		NextAttemptAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Error retrying webhook delivery: %s", err)
+		respondError(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if retried == 0 {
+		respondError(w, "Delivery not found, or still pending", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}